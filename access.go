@@ -0,0 +1,99 @@
+package main
+
+import "context"
+
+// repoAccess lists who may read a repository. A repository without any
+// users or groups is readable by everyone.
+type repoAccess struct {
+	users  map[string]bool
+	groups map[string]bool
+}
+
+func newRepoAccess(users, groups []string) repoAccess {
+	access := repoAccess{}
+	for _, name := range users {
+		if access.users == nil {
+			access.users = make(map[string]bool)
+		}
+		access.users[name] = true
+	}
+	for _, group := range groups {
+		if access.groups == nil {
+			access.groups = make(map[string]bool)
+		}
+		access.groups[group] = true
+	}
+	return access
+}
+
+func (access repoAccess) restricted() bool {
+	return len(access.users) > 0 || len(access.groups) > 0
+}
+
+func (access repoAccess) allows(u *user) bool {
+	if !access.restricted() {
+		return true
+	}
+	if u == nil {
+		return false
+	}
+	if access.users[u.Name] {
+		return true
+	}
+	for _, group := range u.Groups {
+		if access.groups[group] {
+			return true
+		}
+	}
+	return false
+}
+
+// canRead reports whether the user in ctx may read repoName.
+func (a *app) canRead(ctx context.Context, repoName string) bool {
+	_, ok := a.readableRepoPath(ctx, repoName)
+	return ok
+}
+
+// readableRepoPath returns the path of repoName if the user in ctx may read it.
+func (a *app) readableRepoPath(ctx context.Context, repoName string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.readableRepoPathLocked(ctx, repoName)
+}
+
+func (a *app) readableRepoPathLocked(ctx context.Context, repoName string) (string, bool) {
+	repoPath, ok := a.repos[repoName]
+	if !ok || !a.access[repoName].allows(userFromContext(ctx)) {
+		return "", false
+	}
+	return repoPath, true
+}
+
+// visibleRepos returns the names of the repositories the user in ctx may read,
+// in configuration order.
+func (a *app) visibleRepos(ctx context.Context) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.repoNames))
+	for _, name := range a.repoNames {
+		if _, ok := a.readableRepoPathLocked(ctx, name); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// readableRepoAt returns the name of a repository configured at repoPath
+// that the user in ctx may read.
+func (a *app) readableRepoAt(ctx context.Context, repoPath string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, name := range a.repoNames {
+		if path, ok := a.readableRepoPathLocked(ctx, name); ok && path == repoPath {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepoAccessFiltersReposAndHidesRestrictedOnes(t *testing.T) {
+	repoPath, _, hashMove, _, newPath := setupRepoWithRenamedFileForMainTests(t)
+
+	a := &app{
+		repos:     map[string]string{"public": repoPath, "secret": repoPath},
+		repoNames: []string{"public", "secret"},
+		access: map[string]repoAccess{
+			"secret": newRepoAccess([]string{"alice"}, []string{"ops"}),
+		},
+	}
+
+	anonymous := context.Background()
+	alice := context.WithValue(context.Background(), userContextKey{}, &user{Name: "alice"})
+	ops := context.WithValue(context.Background(), userContextKey{}, &user{Name: "bob", Groups: []string{"ops"}})
+	other := context.WithValue(context.Background(), userContextKey{}, &user{Name: "carol", Groups: []string{"dev"}})
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{name: "anonymous", ctx: anonymous, want: []string{"public"}},
+		{name: "listed user", ctx: alice, want: []string{"public", "secret"}},
+		{name: "group member", ctx: ops, want: []string{"public", "secret"}},
+		{name: "other user", ctx: other, want: []string{"public"}},
+	}
+	for _, tc := range cases {
+		if got := a.visibleRepos(tc.ctx); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: visible repos mismatch: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		ctx  context.Context
+		want int
+	}{{ctx: other, want: 404}, {ctx: ops, want: 200}} {
+		req := httptest.NewRequest("GET", "/repo/secret/tree/HEAD/", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("repo", "secret")
+		rctx.URLParams.Add("rev", "HEAD")
+		req = req.WithContext(context.WithValue(tc.ctx, chi.RouteCtxKey, rctx))
+
+		rr := httptest.NewRecorder()
+		a.treeHandler(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("unexpected status code for %v: got %d want %d", userFromContext(tc.ctx), rr.Code, tc.want)
+		}
+	}
+
+	// Restricted content must not end up in shared caches.
+	req := httptest.NewRequest("GET", "/repo/secret/raw/"+hashMove+"/"+newPath, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("repo", "secret")
+	rctx.URLParams.Add("rev", hashMove)
+	rctx.URLParams.Add("*", newPath)
+	req = req.WithContext(context.WithValue(ops, chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	a.rawHandler(rr, req)
+	if cc := rr.Header().Get("Cache-Control"); rr.Code != 200 || cc != "private, max-age=31536000, immutable" {
+		t.Fatalf("expected private immutable raw file, got %d with Cache-Control %q", rr.Code, cc)
+	}
+}
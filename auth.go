@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type authConfig struct {
+	// Htpasswd is the path to an htpasswd file with bcrypt hashes. When set,
+	// clients authenticate with HTTP Basic auth.
+	Htpasswd string `json:"htpasswd"`
+	// Realm is sent in the Basic auth challenge.
+	Realm string `json:"realm"`
+	// ProxyHeader names a header, e.g. "X-Forwarded-User", carrying the user
+	// authenticated by a reverse proxy. It is only honored for requests from
+	// TrustedProxies.
+	ProxyHeader    string   `json:"proxyHeader"`
+	TrustedProxies []string `json:"trustedProxies"`
+	// Groups maps group names to their members, for use in repo access lists.
+	Groups map[string][]string `json:"groups"`
+	// OIDC enables login through an OpenID Connect provider.
+	OIDC *oidcConfig `json:"oidc"`
+}
+
+type user struct {
+	Name   string
+	Groups []string
+	// Session is set for users logged in through OIDC, who can log out.
+	Session bool
+}
+
+type userContextKey struct{}
+
+// userFromContext returns the authenticated user, or nil when authentication
+// is disabled.
+func userFromContext(ctx context.Context) *user {
+	u, _ := ctx.Value(userContextKey{}).(*user)
+	return u
+}
+
+type authenticator struct {
+	realm          string
+	passwords      map[string][]byte
+	proxyHeader    string
+	trustedProxies []*net.IPNet
+	groups         map[string][]string // user name to group names
+	oidc           *oidcLogin
+
+	// verified remembers credentials that already passed bcrypt, so browsing
+	// does not pay the hashing cost on every request.
+	verified sync.Map
+}
+
+// newAuthenticator builds the authenticator described by cfg. It returns nil
+// if cfg enables no authentication method.
+func newAuthenticator(ctx context.Context, cfg authConfig) (*authenticator, error) {
+	if cfg.Htpasswd == "" && cfg.ProxyHeader == "" && cfg.OIDC == nil {
+		return nil, nil
+	}
+
+	auth := &authenticator{
+		realm:       cfg.Realm,
+		proxyHeader: cfg.ProxyHeader,
+		groups:      make(map[string][]string),
+	}
+	for group, members := range cfg.Groups {
+		for _, member := range members {
+			auth.groups[member] = append(auth.groups[member], group)
+		}
+	}
+	if auth.realm == "" {
+		auth.realm = "GitBrowser"
+	}
+
+	if cfg.Htpasswd != "" {
+		passwords, err := loadHtpasswd(cfg.Htpasswd)
+		if err != nil {
+			return nil, err
+		}
+		auth.passwords = passwords
+	}
+
+	if cfg.ProxyHeader != "" {
+		if len(cfg.TrustedProxies) == 0 {
+			return nil, fmt.Errorf("auth.proxyHeader requires auth.trustedProxies")
+		}
+		for _, proxy := range cfg.TrustedProxies {
+			network, err := parseIPNet(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth.trustedProxies entry %q: %w", proxy, err)
+			}
+			auth.trustedProxies = append(auth.trustedProxies, network)
+		}
+	}
+
+	if cfg.OIDC != nil {
+		login, err := newOIDCLogin(ctx, *cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		auth.oidc = login
+	}
+
+	return auth, nil
+}
+
+// loadHtpasswd reads "user:hash" lines. Only bcrypt hashes are accepted.
+func loadHtpasswd(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read htpasswd %q: %w", path, err)
+	}
+	defer file.Close()
+
+	passwords := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, hash, ok := strings.Cut(line, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("htpasswd %q line %d: expected user:hash", path, lineNo)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("htpasswd %q line %d: user %q does not use a bcrypt hash", path, lineNo, name)
+		}
+		passwords[name] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read htpasswd %q: %w", path, err)
+	}
+	return passwords, nil
+}
+
+func parseIPNet(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		return network, err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP address or CIDR")
+	}
+	bits := 8 * len(ip.To16())
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// authenticate returns the user making the request, or nil if the request
+// carries no valid credentials.
+func (auth *authenticator) authenticate(r *http.Request) *user {
+	if auth.oidc != nil {
+		if name, groups, ok := auth.oidc.sessionUser(r); ok {
+			u := auth.newUser(name)
+			u.Groups = append(groups, u.Groups...)
+			u.Session = true
+			return u
+		}
+	}
+
+	if auth.proxyHeader != "" && auth.fromTrustedProxy(r) {
+		if name := strings.TrimSpace(r.Header.Get(auth.proxyHeader)); name != "" {
+			return auth.newUser(name)
+		}
+	}
+
+	if auth.passwords == nil {
+		return nil
+	}
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	hash, known := auth.passwords[name]
+	if !known {
+		return nil
+	}
+
+	key := sha256.Sum256([]byte(name + "\x00" + password + "\x00" + string(hash)))
+	if _, ok := auth.verified.Load(key); ok {
+		return auth.newUser(name)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return nil
+	}
+	auth.verified.Store(key, struct{}{})
+	return auth.newUser(name)
+}
+
+func (auth *authenticator) newUser(name string) *user {
+	return &user{Name: name, Groups: auth.groups[name]}
+}
+
+func (auth *authenticator) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range auth.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// challenge asks the client for credentials: browsers are sent to the OIDC
+// login, other clients get a Basic auth challenge, or are refused when Basic
+// auth is not available.
+func (auth *authenticator) challenge(w http.ResponseWriter, r *http.Request) {
+	if auth.oidc != nil && r.Method == http.MethodGet && !wantsJSON(r) {
+		http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	if auth.passwords != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+strings.ReplaceAll(auth.realm, `"`, "")+`", charset="UTF-8"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if auth.oidc != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
+
+// authMiddleware rejects unauthenticated requests and stores the user in the
+// request context. Static assets stay public so error pages are styled, and
+// the OIDC login endpoints must be reachable before logging in.
+func (a *app) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := a.auth
+		if auth == nil || strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/auth/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := auth.authenticate(r)
+		if u == nil {
+			auth.challenge(w, r)
+			return
+		}
+		// Responses depend on who is signed in; caches must keep them apart.
+		vary := "Authorization, Cookie"
+		if auth.proxyHeader != "" {
+			vary += ", " + auth.proxyHeader
+		}
+		w.Header().Add("Vary", vary)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, u)))
+	})
+}
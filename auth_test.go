@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthMiddlewareAcceptsHtpasswdCredentials(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	htpasswd := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswd, []byte("# users\nalice:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatalf("write htpasswd: %v", err)
+	}
+
+	auth, err := newAuthenticator(context.Background(), authConfig{Htpasswd: htpasswd})
+	if err != nil {
+		t.Fatalf("newAuthenticator returned error: %v", err)
+	}
+	handler := (&app{auth: auth}).authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(userFromContext(r.Context()).Name))
+	}))
+
+	cases := []struct {
+		name     string
+		user     string
+		password string
+		want     int
+	}{
+		{name: "valid", user: "alice", password: "secret", want: 200},
+		{name: "valid again from cache", user: "alice", password: "secret", want: 200},
+		{name: "wrong password", user: "alice", password: "nope", want: 401},
+		{name: "unknown user", user: "bob", password: "secret", want: 401},
+		{name: "no credentials", want: 401},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/repo/testrepo/", nil)
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.password)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tc.name, rr.Code, tc.want)
+		}
+		if tc.want == 401 && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s: expected Basic auth challenge", tc.name)
+		}
+		if tc.want == 200 && rr.Body.String() != "alice" {
+			t.Fatalf("%s: expected user alice in context, got %q", tc.name, rr.Body.String())
+		}
+		if tc.want == 200 && rr.Header().Get("Vary") != "Authorization, Cookie" {
+			t.Fatalf("%s: expected responses to vary by credentials, got Vary %q", tc.name, rr.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestAuthMiddlewareTrustsProxyHeaderOnlyFromTrustedProxies(t *testing.T) {
+	auth, err := newAuthenticator(context.Background(), authConfig{
+		ProxyHeader:    "X-Forwarded-User",
+		TrustedProxies: []string{"10.0.0.0/8", "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("newAuthenticator returned error: %v", err)
+	}
+	handler := (&app{auth: auth}).authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(userFromContext(r.Context()).Name))
+	}))
+
+	cases := []struct {
+		remoteAddr string
+		header     string
+		want       int
+	}{
+		{remoteAddr: "10.1.2.3:4567", header: "carol", want: 200},
+		{remoteAddr: "127.0.0.1:4567", header: "carol", want: 200},
+		{remoteAddr: "192.168.1.5:4567", header: "carol", want: 403},
+		{remoteAddr: "10.1.2.3:4567", header: "", want: 403},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/repo/testrepo/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.header != "" {
+			req.Header.Set("X-Forwarded-User", tc.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s with %q: unexpected status code: got %d want %d", tc.remoteAddr, tc.header, rr.Code, tc.want)
+		}
+	}
+
+	// Static assets stay reachable for unauthenticated clients.
+	req := httptest.NewRequest("GET", "/static/css/style.css", nil)
+	req.RemoteAddr = "192.168.1.5:4567"
+	rr := httptest.NewRecorder()
+	(&app{auth: auth}).authMiddleware(http.NotFoundHandler()).ServeHTTP(rr, req)
+	if rr.Code != 404 {
+		t.Fatalf("expected static request to pass through, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+const (
+	// defaultScanDepth is how deep below a scan root repositories are looked
+	// for when the config does not say.
+	defaultScanDepth = 1
+	// defaultScanInterval is how often scan roots are searched again.
+	defaultScanInterval = 5 * time.Minute
+)
+
+// discoverRepos finds git working trees and bare repositories below
+// scan.Scan, at most scan.Depth directories deep. Each one inherits the access
+// lists and group of the scan entry and is named after its path relative to
+// the root. Symbolic links to directories are followed.
+func discoverRepos(scan repoConfig) ([]repoConfig, error) {
+	root, err := filepath.Abs(strings.TrimSpace(scan.Scan))
+	if err != nil {
+		return nil, fmt.Errorf("resolve scan root %q: %w", scan.Scan, err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("scan root %q is invalid: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("scan root %q is not a directory", root)
+	}
+	depth := scan.Depth
+	if depth <= 0 {
+		depth = defaultScanDepth
+	}
+	for _, pattern := range scan.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q for scan root %q: %w", pattern, root, err)
+		}
+	}
+
+	var found []repoConfig
+	// Symbolic links to directories are followed; visited holds the real
+	// paths already searched so link cycles and repeated links end there.
+	visited := make(map[string]bool)
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil || visited[real] {
+			return nil
+		}
+		visited[real] = true
+
+		if rel != "" {
+			if isGitRepository(dir) {
+				found = append(found, repoConfig{
+					Name:       scannedRepoName(rel),
+					Path:       dir,
+					Group:      scan.Group,
+					Users:      scan.Users,
+					Groups:     scan.Groups,
+					discovered: true,
+				})
+				return nil
+			}
+			if strings.Count(rel, "/")+1 >= depth {
+				return nil
+			}
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the scan.
+			if rel != "" {
+				return nil
+			}
+			return err
+		}
+		for _, entry := range entries {
+			child := filepath.Join(dir, entry.Name())
+			if entry.Type()&fs.ModeSymlink != 0 {
+				info, err := os.Stat(child)
+				if err != nil || !info.IsDir() {
+					continue
+				}
+			} else if !entry.IsDir() {
+				continue
+			}
+			childRel := path.Join(rel, entry.Name())
+			if strings.HasPrefix(entry.Name(), ".") || excluded(childRel, scan.Exclude) {
+				continue
+			}
+			if err := walk(child, childRel); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, fmt.Errorf("scan %q: %w", root, err)
+	}
+	return found, nil
+}
+
+// excluded reports whether rel, or its last element, matches one of patterns.
+func excluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitRepository reports whether dir is a working tree (it has a .git
+// directory or file) or looks like a bare repository.
+func isGitRepository(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	head, err := os.Stat(filepath.Join(dir, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	for _, sub := range []string{"objects", "refs"} {
+		info, err := os.Stat(filepath.Join(dir, sub))
+		if err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// scannedRepoName turns a relative path like "team/api.git" into "team/api".
+func scannedRepoName(rel string) string {
+	return strings.TrimSuffix(rel, ".git")
+}
+
+// uniqueRepoName returns name, or name with a numeric suffix if it is taken.
+func uniqueRepoName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := name + "-" + strconv.Itoa(i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// discoverWorktrees returns the linked worktrees of repo, named
+// "<repo name>/<directory name>". Worktrees whose directory is gone are left
+// out. Like scanned repos, they inherit the access lists and group of repo.
+func discoverWorktrees(repo repoConfig) []repoConfig {
+	repoPath, err := filepath.Abs(strings.TrimSpace(repo.Path))
+	if err != nil {
+		return nil
+	}
+	worktrees, err := git.GetWorktrees(context.Background(), repoPath)
+	if err != nil {
+		log.Printf("Listing worktrees of repo %q: %v", repo.Name, err)
+		return nil
+	}
+
+	var found []repoConfig
+	for _, wt := range worktrees {
+		if wt.Main || wt.Bare || wt.Prunable {
+			continue
+		}
+		name := strings.TrimSpace(repo.Name) + "/" + filepath.Base(wt.Path)
+		if err := validateRepoName(name); err != nil {
+			log.Printf("Skipping worktree %q of repo %q: %v", wt.Path, repo.Name, err)
+			continue
+		}
+		found = append(found, repoConfig{
+			Name:       name,
+			Path:       wt.Path,
+			Group:      repo.Group,
+			Users:      repo.Users,
+			Groups:     repo.Groups,
+			discovered: true,
+		})
+	}
+	return found
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverReposFindsWorkingTreesAndBareRepos(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"app", "team/api", "team/nested/too-deep", "archive/old", ".hidden/repo"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		runGitMainTest(t, filepath.Join(root, dir), "init", "-q")
+	}
+	runGitMainTest(t, root, "init", "-q", "--bare", filepath.Join(root, "team/mirror.git"))
+
+	found, err := discoverRepos(repoConfig{Scan: root, Depth: 2, Exclude: []string{"archive"}, Groups: []string{"devs"}})
+	if err != nil {
+		t.Fatalf("discoverRepos returned error: %v", err)
+	}
+
+	var names []string
+	for _, repo := range found {
+		names = append(names, repo.Name)
+		if !reflect.DeepEqual(repo.Groups, []string{"devs"}) {
+			t.Fatalf("expected %s to inherit the scan groups, got %v", repo.Name, repo.Groups)
+		}
+	}
+	want := []string{"app", "team/api", "team/mirror"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected discovered repos: got %v want %v", names, want)
+	}
+}
+
+func TestDiscoverReposFollowsDirectorySymlinksOnce(t *testing.T) {
+	root := t.TempDir()
+	elsewhere := t.TempDir()
+	for _, dir := range []string{filepath.Join(root, "app"), filepath.Join(elsewhere, "svc")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		runGitMainTest(t, dir, "init", "-q")
+	}
+	links := map[string]string{
+		"linked":   elsewhere,
+		"loop":     root,
+		"same-app": filepath.Join(root, "app"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatalf("symlink: %v", err)
+		}
+	}
+
+	found, err := discoverRepos(repoConfig{Scan: root, Depth: 3})
+	if err != nil {
+		t.Fatalf("discoverRepos returned error: %v", err)
+	}
+	var names []string
+	for _, repo := range found {
+		names = append(names, repo.Name)
+	}
+	want := []string{"app", "linked/svc"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected discovered repos: got %v want %v", names, want)
+	}
+}
+
+func TestExpandScansKeepsConfiguredNamesUnique(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "app"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	runGitMainTest(t, filepath.Join(root, "app"), "init", "-q")
+
+	entries, interval, err := expandScans(appConfig{Repos: []repoConfig{
+		{Scan: root},
+		{Name: "app", Path: "/configured/app"},
+	}})
+	if err != nil {
+		t.Fatalf("expandScans returned error: %v", err)
+	}
+	if interval != defaultScanInterval {
+		t.Fatalf("unexpected scan interval: %v", interval)
+	}
+	if len(entries) != 2 || entries[0].Name != "app-2" || entries[1].Name != "app" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/andrebering/gitBrowser/git"
+	"github.com/go-chi/chi/v5"
+)
+
+// retryAfterSeconds is sent with 503 responses when git processes are saturated.
+const retryAfterSeconds = "5"
+
+type errorViewData struct {
+	baseViewData
+	Status  int
+	Title   string
+	Message string
+}
+
+// classifyError maps err to an HTTP status and a message that is safe to show
+// to clients. Details such as git's stderr are only logged.
+func classifyError(err error) (int, string) {
+	var unknownRev *git.UnknownRevisionError
+	switch {
+	case errors.As(err, &unknownRev):
+		return http.StatusNotFound, unknownRev.Error()
+	case errors.Is(err, git.ErrBadRevision):
+		return http.StatusNotFound, "Unknown revision."
+	case errors.Is(err, git.ErrNotFound):
+		return http.StatusNotFound, "The requested path or object does not exist."
+	case errors.Is(err, git.ErrBusy):
+		return http.StatusServiceUnavailable, "The server is busy. Please try again shortly."
+	case errors.Is(err, git.ErrTimeout):
+		return http.StatusGatewayTimeout, "The git command took too long to complete."
+	case errors.Is(err, git.ErrTooLarge):
+		return http.StatusUnprocessableEntity, "The requested content is too large to display."
+	case errors.Is(err, git.ErrNotRepository):
+		return http.StatusInternalServerError, "The repository is not available."
+	case errors.Is(err, context.Canceled):
+		// The client went away; nobody will read the response.
+		return http.StatusServiceUnavailable, "The request was canceled."
+	}
+	return http.StatusInternalServerError, "Something went wrong while reading the repository."
+}
+
+// renderError writes err as a styled error page, or as JSON for clients that
+// ask for it.
+func (a *app) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := classifyError(err)
+	if status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	// Errors are never cached, even where the page would have been immutable.
+	w.Header().Set("Cache-Control", "no-store")
+	if errors.Is(err, git.ErrBusy) {
+		w.Header().Set("Retry-After", retryAfterSeconds)
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(struct {
+			Error  string `json:"error"`
+			Status int    `json:"status"`
+		}{Error: message, Status: status})
+		return
+	}
+
+	// Only link back to the repository if it exists; the error may be that it doesn't.
+	repoName := chi.URLParam(r, "repo")
+	if !a.canRead(r.Context(), repoName) {
+		repoName = ""
+	}
+	data := errorViewData{
+		baseViewData: baseViewData{
+			Repo:  repoName,
+			Repos: a.visibleRepos(r.Context()),
+			Rev:   chi.URLParam(r, "rev"),
+			User:  userFromContext(r.Context()),
+		},
+		Status:  status,
+		Title:   http.StatusText(status),
+		Message: message,
+	}
+
+	w.WriteHeader(status)
+	if err := templates.ExecuteTemplate(w, "error.html", data); err != nil {
+		log.Printf("render error page: %v", err)
+	}
+}
+
+func (a *app) notFound(w http.ResponseWriter, r *http.Request) {
+	a.renderError(w, r, git.ErrNotFound)
+}
+
+// wantsJSON reports whether the client prefers a JSON response over HTML.
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
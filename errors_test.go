@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andrebering/gitBrowser/git"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTreeHandlerReportsMissingPathAsNotFound(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+
+	req := httptest.NewRequest("GET", "/repo/testrepo/tree/HEAD/missing", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("repo", "testrepo")
+	rctx.URLParams.Add("rev", "HEAD")
+	rctx.URLParams.Add("*", "missing")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	a.treeHandler(rr, req)
+
+	if rr.Code != 404 {
+		t.Fatalf("unexpected status code: got %d want 404", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "does not exist") {
+		t.Fatalf("expected styled error page, got body %q", body)
+	}
+	if strings.Contains(body, "exit status") {
+		t.Fatalf("expected git stderr not to leak into the page, got body %q", body)
+	}
+}
+
+func TestRenderErrorReturnsJSONForAPIClients(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+
+	req := httptest.NewRequest("GET", "/repo/testrepo/commits/nope", nil)
+	req.Header.Set("Accept", "application/json")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("repo", "testrepo")
+	rctx.URLParams.Add("rev", "nope")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	a.commitsHandler(rr, req)
+
+	if rr.Code != 404 {
+		t.Fatalf("unexpected status code: got %d want 404", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var payload struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode JSON error: %v", err)
+	}
+	if payload.Status != 404 || !strings.Contains(payload.Error, "nope") {
+		t.Fatalf("unexpected error payload %+v", payload)
+	}
+}
+
+func TestRenderErrorSetsRetryAfterWhenBusy(t *testing.T) {
+	a := &app{repos: map[string]string{}}
+
+	req := httptest.NewRequest("GET", "/repo/testrepo/commits", nil)
+	rr := httptest.NewRecorder()
+	a.renderError(rr, req, fmt.Errorf("wrapped: %w", git.ErrBusy))
+
+	if rr.Code != 503 {
+		t.Fatalf("unexpected status code: got %d want 503", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header on 503 response")
+	}
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestBareRepositoryIsBrowsable(t *testing.T) {
+	source, _, hashMove, _, newPath := setupRepoWithRenamedFile(t)
+	branch := runGit(t, source, "branch", "--show-current")
+	bare := filepath.Join(t.TempDir(), "mirror.git")
+	runGit(t, source, "clone", "-q", "--mirror", source, bare)
+	// Mirrors of repositories whose default branch was renamed keep a stale HEAD.
+	runGit(t, bare, "symbolic-ref", "HEAD", "refs/heads/does-not-exist")
+	ctx := context.Background()
+
+	if err := ValidateRepository(ctx, bare); err != nil {
+		t.Fatalf("ValidateRepository rejected bare repo: %v", err)
+	}
+	if isBare, err := IsBareRepository(ctx, bare); err != nil || !isBare {
+		t.Fatalf("IsBareRepository = %v, %v; want true", isBare, err)
+	}
+	if isBare, err := IsBareRepository(ctx, source); err != nil || isBare {
+		t.Fatalf("IsBareRepository(work tree) = %v, %v; want false", isBare, err)
+	}
+	if err := ValidateRepository(ctx, filepath.Join(source, ".git")); !errors.Is(err, ErrNotRepository) {
+		t.Fatalf("expected the .git directory of a work tree to be rejected, got %v", err)
+	}
+
+	defaultBranch, err := GetDefaultBranch(ctx, bare)
+	if err != nil || defaultBranch != branch {
+		t.Fatalf("GetDefaultBranch = %q, %v; want %q", defaultBranch, err, branch)
+	}
+	if defaultBranch, err := GetDefaultBranch(ctx, source); err != nil || defaultBranch != branch {
+		t.Fatalf("GetDefaultBranch(work tree) = %q, %v; want %q", defaultBranch, err, branch)
+	}
+
+	if entries, err := ListTree(ctx, bare, branch, ""); err != nil || len(entries) != 1 {
+		t.Fatalf("ListTree = %v, %v", entries, err)
+	}
+	if _, err := GetFileContent(ctx, bare, branch, newPath); err != nil {
+		t.Fatalf("GetFileContent returned error: %v", err)
+	}
+	if log, err := GetLog(ctx, bare, branch); err != nil || len(log) != 3 {
+		t.Fatalf("GetLog = %d entries, %v", len(log), err)
+	}
+	if history, err := GetFileHistory(ctx, bare, branch, newPath); err != nil || len(history) != 3 {
+		t.Fatalf("GetFileHistory = %d entries, %v", len(history), err)
+	}
+	if _, err := GetCommitDiff(ctx, bare, hashMove); err != nil {
+		t.Fatalf("GetCommitDiff returned error: %v", err)
+	}
+	if last, err := GetLastCommits(ctx, bare, branch, "", []string{"Android"}); err != nil || last["Android"].Hash != hashMove {
+		t.Fatalf("GetLastCommits = %v, %v", last, err)
+	}
+}
@@ -0,0 +1,98 @@
+package git
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheBytes is the size of the object cache until SetCacheSize is called.
+const DefaultCacheBytes = 64 << 20
+
+// objectCache is an LRU cache bounded by the approximate size of its values.
+// It only holds results keyed by immutable object IDs, so entries never need
+// to be invalidated. Cached values are shared between callers and must not be
+// modified.
+type objectCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	value any
+	size  int64
+}
+
+var cache = newObjectCache(DefaultCacheBytes)
+
+func newObjectCache(maxBytes int64) *objectCache {
+	return &objectCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// SetCacheSize sets the maximum number of bytes held by the object cache and
+// evicts entries beyond it. A zero or negative size disables caching.
+func SetCacheSize(maxBytes int64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.maxBytes = maxBytes
+	cache.evict()
+}
+
+func (c *objectCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).value, true
+}
+
+func (c *objectCache) add(key string, value any, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if size > c.maxBytes {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		c.size -= elem.Value.(*cacheEntry).size
+		c.order.Remove(elem)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, value: value, size: size})
+	c.size += size
+	c.evict()
+}
+
+func (c *objectCache) evict() {
+	for c.size > c.maxBytes && c.order.Len() > 0 {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.items, entry.key)
+		c.size -= entry.size
+	}
+}
+
+// cached returns the value stored under key, or computes, stores and returns
+// it. sizeOf estimates the memory held by a computed value.
+func cached[T any](key string, compute func() (T, error), sizeOf func(T) int64) (T, error) {
+	if value, ok := cache.get(key); ok {
+		return value.(T), nil
+	}
+	value, err := compute()
+	if err != nil {
+		return value, err
+	}
+	cache.add(key, value, sizeOf(value)+int64(len(key)))
+	return value, nil
+}
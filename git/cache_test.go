@@ -0,0 +1,32 @@
+package git
+
+import "testing"
+
+func TestObjectCacheEvictsLeastRecentlyUsedByBytes(t *testing.T) {
+	c := newObjectCache(10)
+
+	c.add("a", "aaaa", 4)
+	c.add("b", "bbbb", 4)
+	if _, ok := c.get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	// Adding c exceeds the budget; b is now the least recently used entry.
+	c.add("c", "cccc", 4)
+
+	if _, ok := c.get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Fatalf("expected %s to be cached", key)
+		}
+	}
+	if c.size != 8 {
+		t.Fatalf("unexpected cache size: got %d want 8", c.size)
+	}
+
+	c.add("huge", "x", 11)
+	if _, ok := c.get("huge"); ok {
+		t.Fatalf("expected entry larger than the cache to be skipped")
+	}
+}
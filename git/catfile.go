@@ -0,0 +1,249 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ObjectInfo describes a git object as reported by cat-file.
+type ObjectInfo struct {
+	Hash string
+	Type string
+	Size int64
+}
+
+// catFile is a long-running `git cat-file --batch` or `--batch-check` process.
+// Requests are serialized, so a single process can be shared by concurrent
+// callers; a process that fails mid-conversation, or whose caller gives up
+// while it is answering, is discarded and restarted on the next call.
+type catFile struct {
+	repoPath string
+	mode     string
+	// transient processes are stopped after every request.
+	transient bool
+
+	// lock is a one-slot semaphore so waiting callers can honor their context.
+	lock   chan struct{}
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func newCatFile(repoPath, mode string) *catFile {
+	return &catFile{repoPath: repoPath, mode: mode, lock: make(chan struct{}, 1)}
+}
+
+type objectReader struct {
+	batch *catFile
+	check *catFile
+}
+
+var objectReaders = struct {
+	sync.Mutex
+	byRepo map[string]*objectReader
+	// closed holds repositories whose readers were closed while requests for
+	// them may still be in flight.
+	closed map[string]bool
+}{byRepo: make(map[string]*objectReader), closed: make(map[string]bool)}
+
+func objectReaderFor(repoPath string) *objectReader {
+	objectReaders.Lock()
+	defer objectReaders.Unlock()
+
+	if objectReaders.closed[repoPath] {
+		// Serve late requests without leaving processes behind.
+		reader := &objectReader{
+			batch: newCatFile(repoPath, "--batch"),
+			check: newCatFile(repoPath, "--batch-check"),
+		}
+		reader.batch.transient = true
+		reader.check.transient = true
+		return reader
+	}
+	reader, ok := objectReaders.byRepo[repoPath]
+	if !ok {
+		reader = &objectReader{
+			batch: newCatFile(repoPath, "--batch"),
+			check: newCatFile(repoPath, "--batch-check"),
+		}
+		objectReaders.byRepo[repoPath] = reader
+	}
+	return reader
+}
+
+// CloseObjectReaders stops all cat-file processes. It is meant to be called on
+// server shutdown; readers are restarted lazily if used afterwards.
+func CloseObjectReaders() {
+	objectReaders.Lock()
+	defer objectReaders.Unlock()
+
+	for repoPath, reader := range objectReaders.byRepo {
+		reader.batch.close()
+		reader.check.close()
+		delete(objectReaders.byRepo, repoPath)
+	}
+}
+
+// CloseObjectReader stops the cat-file processes of one repository, e.g. after
+// it was removed from the configuration. Requests still running for it get a
+// process of their own until ReopenObjectReader is called for the path.
+func CloseObjectReader(repoPath string) {
+	objectReaders.Lock()
+	reader, ok := objectReaders.byRepo[repoPath]
+	delete(objectReaders.byRepo, repoPath)
+	objectReaders.closed[repoPath] = true
+	objectReaders.Unlock()
+
+	if ok {
+		reader.batch.close()
+		reader.check.close()
+	}
+}
+
+// ReopenObjectReader lets a repository closed by CloseObjectReader keep
+// long-running cat-file processes again, e.g. when it is configured anew.
+func ReopenObjectReader(repoPath string) {
+	objectReaders.Lock()
+	defer objectReaders.Unlock()
+	delete(objectReaders.closed, repoPath)
+}
+
+// readObject returns the type, size and content of the object named by spec,
+// e.g. "HEAD:README.md". Objects larger than MaxBlobBytes fail with a
+// *TooLargeError.
+func readObject(ctx context.Context, repoPath, spec string) (ObjectInfo, []byte, error) {
+	return objectReaderFor(repoPath).batch.read(ctx, spec, currentLimits().MaxBlobBytes)
+}
+
+// statObject returns the type and size of the object named by spec without
+// reading its content.
+func statObject(ctx context.Context, repoPath, spec string) (ObjectInfo, error) {
+	info, _, err := objectReaderFor(repoPath).check.read(ctx, spec, 0)
+	return info, err
+}
+
+func (c *catFile) read(ctx context.Context, spec string, maxSize int64) (ObjectInfo, []byte, error) {
+	if spec == "" || strings.ContainsAny(spec, "\n\r") {
+		return ObjectInfo{}, nil, fmt.Errorf("invalid object name %q", spec)
+	}
+
+	ctx, cancel := withCommandTimeout(ctx)
+	defer cancel()
+
+	select {
+	case c.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ObjectInfo{}, nil, contextError(ctx, []string{"cat-file", c.mode})
+	}
+	defer func() { <-c.lock }()
+
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return ObjectInfo{}, nil, err
+		}
+	}
+
+	// Killing the process unblocks a request stuck on a slow object read.
+	process := c.cmd.Process
+	stopKill := context.AfterFunc(ctx, func() { _ = process.Kill() })
+	info, content, err := c.request(spec, maxSize)
+	killed := !stopKill()
+
+	failed := err != nil && !errors.Is(err, ErrNotFound)
+	if failed || killed || c.transient {
+		// The stream is out of sync or the process died; start over next time.
+		// This includes skipping the unread content of an oversized object.
+		// Transient processes are never kept.
+		c.stop()
+	}
+	if failed && !errors.Is(err, ErrTooLarge) {
+		if ctxErr := contextError(ctx, []string{"cat-file", c.mode}); ctxErr != nil {
+			return ObjectInfo{}, nil, ctxErr
+		}
+	}
+	return info, content, err
+}
+
+func (c *catFile) request(spec string, maxSize int64) (ObjectInfo, []byte, error) {
+	if _, err := io.WriteString(c.stdin, spec+"\n"); err != nil {
+		return ObjectInfo{}, nil, fmt.Errorf("cat-file write: %w", err)
+	}
+
+	header, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return ObjectInfo{}, nil, fmt.Errorf("cat-file read: %w", err)
+	}
+	header = strings.TrimSuffix(header, "\n")
+	if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+		return ObjectInfo{}, nil, fmt.Errorf("%w: %s", ErrNotFound, spec)
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return ObjectInfo{}, nil, fmt.Errorf("cat-file: unexpected header %q", header)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return ObjectInfo{}, nil, fmt.Errorf("cat-file: unexpected header %q", header)
+	}
+	info := ObjectInfo{Hash: fields[0], Type: fields[1], Size: size}
+	if c.mode != "--batch" {
+		return info, nil, nil
+	}
+	if size > maxSize {
+		return info, nil, &TooLargeError{Limit: maxSize}
+	}
+
+	// Content is followed by a single newline.
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(c.stdout, content); err != nil {
+		return ObjectInfo{}, nil, fmt.Errorf("cat-file read: %w", err)
+	}
+	return info, content[:size], nil
+}
+
+func (c *catFile) start() error {
+	cmd := exec.Command("git", "cat-file", c.mode)
+	cmd.Dir = c.repoPath
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	c.cmd = cmd
+	c.stdin = stdin
+	c.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+func (c *catFile) stop() {
+	if c.cmd == nil {
+		return
+	}
+	_ = c.stdin.Close()
+	_ = c.cmd.Process.Kill()
+	_ = c.cmd.Wait()
+	c.cmd = nil
+	c.stdin = nil
+	c.stdout = nil
+}
+
+func (c *catFile) close() {
+	c.lock <- struct{}{}
+	defer func() { <-c.lock }()
+	c.stop()
+}
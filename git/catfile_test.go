@@ -0,0 +1,81 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestObjectReaderServesConcurrentRequests(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFile(t)
+	defer CloseObjectReaders()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := GetFileContent(context.Background(), repoPath, "HEAD", newPath)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !strings.Contains(content, "toUri") {
+				t.Errorf("unexpected content %q", content)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("GetFileContent returned error: %v", err)
+	}
+
+	// A missing object must not break the shared process for later requests.
+	if _, err := GetFileContent(context.Background(), repoPath, "HEAD", "does/not/exist"); err == nil {
+		t.Fatalf("expected error for missing path")
+	}
+	entries, err := ListTree(context.Background(), repoPath, "HEAD", "Android")
+	if err != nil {
+		t.Fatalf("ListTree returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Type != "tree" || entries[0].Mode != "040000" || entries[0].Path != "Android/androidApp" {
+		t.Fatalf("unexpected tree entries %+v", entries)
+	}
+}
+
+func TestClosedObjectReaderIsNotRecreated(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFile(t)
+	defer CloseObjectReaders()
+
+	if _, err := GetFileContent(context.Background(), repoPath, "HEAD", newPath); err != nil {
+		t.Fatalf("GetFileContent returned error: %v", err)
+	}
+	CloseObjectReader(repoPath)
+	defer ReopenObjectReader(repoPath)
+
+	// A request that was in flight when the repository was removed still works,
+	// but must not register long-running processes again.
+	if _, err := GetFileContent(context.Background(), repoPath, "HEAD", newPath); err != nil {
+		t.Fatalf("GetFileContent after close returned error: %v", err)
+	}
+	objectReaders.Lock()
+	_, registered := objectReaders.byRepo[repoPath]
+	objectReaders.Unlock()
+	if registered {
+		t.Fatalf("expected no object reader for a closed repository")
+	}
+
+	ReopenObjectReader(repoPath)
+	if _, err := GetFileContent(context.Background(), repoPath, "HEAD", newPath); err != nil {
+		t.Fatalf("GetFileContent after reopen returned error: %v", err)
+	}
+	objectReaders.Lock()
+	_, registered = objectReaders.byRepo[repoPath]
+	objectReaders.Unlock()
+	if !registered {
+		t.Fatalf("expected the object reader to be registered again")
+	}
+}
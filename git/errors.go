@@ -0,0 +1,27 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds returned by this package. Callers should test for them with
+// errors.Is; the concrete errors wrap them with details such as git's stderr.
+var (
+	// ErrNotFound means a path or object does not exist at the requested revision.
+	ErrNotFound = errors.New("not found")
+	// ErrBadRevision means a revision is malformed or unknown; see UnknownRevisionError.
+	ErrBadRevision = errors.New("bad revision")
+	// ErrNotRepository means a directory is not a usable git repository.
+	ErrNotRepository = errors.New("not a git repository")
+	// ErrTimeout is returned when a git command runs longer than the configured
+	// command timeout.
+	ErrTimeout = errors.New("git command timed out")
+	// ErrTooLarge means an object or command output exceeds the configured size limit.
+	ErrTooLarge = errors.New("output too large")
+	// ErrBusy means no git process slot became free within the queue timeout.
+	ErrBusy = errors.New("too many concurrent git commands")
+	// ErrNoWorkTree is returned for work tree pages of bare repositories. It
+	// is a kind of ErrNotFound.
+	ErrNoWorkTree = fmt.Errorf("%w: repository has no work tree", ErrNotFound)
+)
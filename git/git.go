@@ -2,33 +2,105 @@ package git
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// DefaultCommandTimeout is the per-command timeout used until SetCommandTimeout is called.
+const DefaultCommandTimeout = 30 * time.Second
+
+var commandTimeout atomic.Int64
+
+func init() {
+	commandTimeout.Store(int64(DefaultCommandTimeout))
+}
+
+// SetCommandTimeout sets the maximum run time of a single git command.
+// A zero or negative duration disables the limit.
+func SetCommandTimeout(d time.Duration) {
+	commandTimeout.Store(int64(d))
+}
+
+// withCommandTimeout derives the context a single git invocation runs under.
+func withCommandTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d := time.Duration(commandTimeout.Load()); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// contextError translates the error of a command whose context ended into
+// ErrTimeout or the cancellation cause. It returns nil if ctx is still live.
+func contextError(ctx context.Context, args []string) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: git %s", ErrTimeout, strings.Join(args, " "))
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+	return nil
+}
+
 // Command runs a native git command in the target repository and returns the output as a string.
-func Command(repoPath string, args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
+// The process is killed when ctx is done or the command timeout expires, and
+// output beyond the MaxLogBytes limit fails with a *TooLargeError.
+func Command(ctx context.Context, repoPath string, args ...string) (string, error) {
+	return commandLimited(ctx, repoPath, currentLimits().MaxLogBytes, args...)
+}
+
+func commandLimited(ctx context.Context, repoPath string, limit int64, args ...string) (string, error) {
+	stdout := &limitedBuffer{limit: limit}
+	err := run(ctx, repoPath, stdout, args...)
+	if stdout.exceeded {
+		return "", &TooLargeError{Limit: limit}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// run executes git with its output written to w.
+func run(ctx context.Context, repoPath string, w io.Writer, args ...string) error {
+	release, err := acquireProcess(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := withCommandTimeout(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = repoPath
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	var stderr bytes.Buffer
+	cmd.Stdout = w
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
-	if err != nil {
+	if err := cmd.Run(); err != nil {
+		if ctxErr := contextError(ctx, args); ctxErr != nil {
+			return ctxErr
+		}
 		if stderr.Len() > 0 {
-			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
 		}
-		return "", err
+		return err
 	}
-
-	return strings.TrimSpace(stdout.String()), nil
+	return nil
 }
 
 // LogEntry represents a single commit log entry.
 type LogEntry struct {
 	Hash    string
+	Parents []string
 	Author  string
 	Date    string
 	Subject string
@@ -41,12 +113,26 @@ type FileHistoryEntry struct {
 }
 
 // GetLog returns the commit history of the repository.
-func GetLog(repoPath, rev string) ([]LogEntry, error) {
+func GetLog(ctx context.Context, repoPath, rev string) ([]LogEntry, error) {
 	if rev == "" {
 		rev = "HEAD"
 	}
-	// Format: hash|author|date|subject
-	out, err := Command(repoPath, "log", rev, "--pretty=format:%H|%an|%ad|%s", "--date=short", "-n", "20")
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return nil, err
+	}
+	return getLog(ctx, repoPath, commit)
+}
+
+// GetAllBranchesLog returns the commit history reachable from any local branch.
+func GetAllBranchesLog(ctx context.Context, repoPath string) ([]LogEntry, error) {
+	return getLog(ctx, repoPath, "--branches")
+}
+
+func getLog(ctx context.Context, repoPath, revArg string) ([]LogEntry, error) {
+	// Format: hash|parents|author|date|subject
+	// --date-order keeps children above their parents, which the commit graph relies on.
+	out, err := Command(ctx, repoPath, "log", revArg, "--date-order", "--pretty=format:%H|%P|%an|%ad|%s", "--date=short", "-n", "20")
 	if err != nil {
 		return nil, err
 	}
@@ -58,13 +144,14 @@ func GetLog(repoPath, rev string) ([]LogEntry, error) {
 	lines := strings.Split(out, "\n")
 	var entries []LogEntry
 	for _, line := range lines {
-		parts := strings.Split(line, "|")
-		if len(parts) == 4 {
+		parts := strings.SplitN(line, "|", 5)
+		if len(parts) == 5 {
 			entries = append(entries, LogEntry{
 				Hash:    parts[0],
-				Author:  parts[1],
-				Date:    parts[2],
-				Subject: parts[3],
+				Parents: strings.Fields(parts[1]),
+				Author:  parts[2],
+				Date:    parts[3],
+				Subject: parts[4],
 			})
 		}
 	}
@@ -82,62 +169,188 @@ type TreeEntry struct {
 }
 
 // ListTree returns the contents of a directory at a specific revision and path.
-func ListTree(repoPath, rev, path string) ([]TreeEntry, error) {
+func ListTree(ctx context.Context, repoPath, rev, path string) ([]TreeEntry, error) {
 	if rev == "" {
 		rev = "HEAD"
 	}
-	// git ls-tree <rev> <path>
-	// Output format: <mode> <type> <hash> <name>
-	args := []string{"ls-tree", rev}
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return nil, err
+	}
+	return listTree(ctx, repoPath, commit, path)
+}
+
+// listTree is ListTree for an already resolved commit.
+func listTree(ctx context.Context, repoPath, commit, path string) ([]TreeEntry, error) {
+	// Ensure path doesn't start with / if it's meant to be relative to repo root
+	path = strings.Trim(path, "/")
+	spec := commit + "^{tree}"
 	if path != "" {
-		// Ensure path doesn't start with / if it's meant to be relative to repo root
-		path = strings.TrimPrefix(path, "/")
-		if path != "" && !strings.HasSuffix(path, "/") {
-			path += "/"
-		}
-		args = append(args, path)
-	} else {
-		args = append(args, ".")
+		spec = commit + ":" + path
 	}
 
-	out, err := Command(repoPath, args...)
+	info, err := statObject(ctx, repoPath, spec)
 	if err != nil {
 		return nil, err
 	}
+	if info.Type != "tree" {
+		return nil, fmt.Errorf("%w: %s is not a directory", ErrNotFound, path)
+	}
 
-	if out == "" {
-		return []TreeEntry{}, nil
+	prefix := ""
+	if path != "" {
+		prefix = path + "/"
 	}
+	return cached("tree\x00"+info.Hash+"\x00"+prefix, func() ([]TreeEntry, error) {
+		_, content, err := readObject(ctx, repoPath, info.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return parseTree(content, len(info.Hash)/2, prefix)
+	}, treeEntriesSize)
+}
 
-	lines := strings.Split(out, "\n")
-	var entries []TreeEntry
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) >= 4 {
-			name := strings.Join(fields[3:], " ")
-			entries = append(entries, TreeEntry{
-				Mode: fields[0],
-				Type: fields[1],
-				Hash: fields[2],
-				Name: strings.TrimPrefix(name, path),
-				Path: name,
-			})
+func treeEntriesSize(entries []TreeEntry) int64 {
+	size := int64(0)
+	for _, entry := range entries {
+		size += int64(len(entry.Mode)+len(entry.Type)+len(entry.Hash)+len(entry.Name)+len(entry.Path)) + 80
+	}
+	return size
+}
+
+// parseTree decodes a raw tree object. Each entry is "<mode> <name>\x00"
+// followed by the binary object id of hashSize bytes.
+func parseTree(content []byte, hashSize int, prefix string) ([]TreeEntry, error) {
+	entries := []TreeEntry{}
+	for len(content) > 0 {
+		space := bytes.IndexByte(content, ' ')
+		if space < 0 {
+			return nil, fmt.Errorf("malformed tree object")
 		}
+		nul := bytes.IndexByte(content[space:], 0)
+		if nul < 0 || space+nul+1+hashSize > len(content) {
+			return nil, fmt.Errorf("malformed tree object")
+		}
+		nul += space
+
+		mode := string(content[:space])
+		name := string(content[space+1 : nul])
+		hash := hex.EncodeToString(content[nul+1 : nul+1+hashSize])
+		content = content[nul+1+hashSize:]
+
+		// Tree objects store modes without leading zeros; ls-tree pads them.
+		if len(mode) < 6 {
+			mode = strings.Repeat("0", 6-len(mode)) + mode
+		}
+		entryType := "blob"
+		switch mode {
+		case "040000":
+			entryType = "tree"
+		case "160000":
+			entryType = "commit"
+		}
+
+		entries = append(entries, TreeEntry{
+			Mode: mode,
+			Type: entryType,
+			Hash: hash,
+			Name: name,
+			Path: prefix + name,
+		})
 	}
 	return entries, nil
 }
 
+// FileInfo describes a file at a resolved commit.
+type FileInfo struct {
+	TreeEntry
+	// Commit is the commit the revision resolved to.
+	Commit string
+	Size   int64
+}
+
+// StatFile returns the tree entry and size of the file at path and rev, so
+// callers can tell symlinks and LFS pointers from regular files before
+// reading any content.
+func StatFile(ctx context.Context, repoPath, rev, path string) (FileInfo, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	path = strings.Trim(path, "/")
+	entry, err := lookupEntry(ctx, repoPath, commit, path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if entry == nil || entry.Type != "blob" {
+		return FileInfo{}, fmt.Errorf("%w: %s is not a file", ErrNotFound, path)
+	}
+	info, err := statObject(ctx, repoPath, entry.Hash)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{TreeEntry: *entry, Commit: commit, Size: info.Size}, nil
+}
+
 // GetFileContent returns the content of a file at a specific revision and path.
-func GetFileContent(repoPath, rev, path string) (string, error) {
+func GetFileContent(ctx context.Context, repoPath, rev, path string) (string, error) {
 	if rev == "" {
 		rev = "HEAD"
 	}
-	return Command(repoPath, "show", rev+":"+path)
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return "", err
+	}
+	info, err := statObject(ctx, repoPath, commit+":"+path)
+	if err != nil {
+		return "", err
+	}
+	if info.Type != "blob" {
+		return "", fmt.Errorf("%w: %s is not a file", ErrNotFound, path)
+	}
+	// Checked here as well so cached content honors a lowered limit.
+	if limit := currentLimits().MaxBlobBytes; info.Size > limit {
+		return "", &TooLargeError{Limit: limit}
+	}
+	return cached("blob\x00"+info.Hash, func() (string, error) {
+		_, content, err := readObject(ctx, repoPath, info.Hash)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\n"), nil
+	}, stringSize)
+}
+
+func stringSize(s string) int64 {
+	return int64(len(s))
+}
+
+// WriteFileContent streams the content of a file at a specific revision and
+// path to w without applying output limits.
+func WriteFileContent(ctx context.Context, repoPath string, w io.Writer, rev, path string) error {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return err
+	}
+	info, err := statObject(ctx, repoPath, commit+":"+path)
+	if err != nil {
+		return err
+	}
+	if info.Type != "blob" {
+		return fmt.Errorf("%w: %s is not a file", ErrNotFound, path)
+	}
+	return run(ctx, repoPath, w, "cat-file", "blob", info.Hash)
 }
 
 // GetBranches returns a list of all local branches.
-func GetBranches(repoPath string) ([]string, error) {
-	out, err := Command(repoPath, "branch", "--format=%(refname:short)")
+func GetBranches(ctx context.Context, repoPath string) ([]string, error) {
+	out, err := Command(ctx, repoPath, "branch", "--format=%(refname:short)")
 	if err != nil {
 		return nil, err
 	}
@@ -148,21 +361,166 @@ func GetBranches(repoPath string) ([]string, error) {
 }
 
 // GetCommitDiff returns the diff of a specific commit.
-func GetCommitDiff(repoPath, hash string) (string, error) {
-	return Command(repoPath, "show", hash)
+func GetCommitDiff(ctx context.Context, repoPath, hash string) (string, error) {
+	return getDiff(ctx, repoPath, hash, "", "")
+}
+
+// Diff modes for merge commits accepted by GetMergeDiff. Any other mode must be
+// the 1-based number of the parent to diff against.
+const (
+	MergeDiffDenseCombined = "cc"
+	MergeDiffCombined      = "combined"
+)
+
+// GetCommitParents returns the parent hashes of a commit in order.
+func GetCommitParents(ctx context.Context, repoPath, hash string) ([]string, error) {
+	commit, err := ResolveRevision(ctx, repoPath, hash)
+	if err != nil {
+		return nil, err
+	}
+	out, err := Command(ctx, repoPath, "rev-list", "--parents", "-n", "1", commit)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return []string{}, nil
+	}
+	return fields[1:], nil
+}
+
+// GetMergeDiff returns the diff of a merge commit in the requested mode: the
+// condensed combined form, the full combined form, or against a single parent.
+func GetMergeDiff(ctx context.Context, repoPath, hash, mode string) (string, error) {
+	if mode == "" {
+		mode = MergeDiffDenseCombined
+	}
+	return getDiff(ctx, repoPath, hash, mode, "")
+}
+
+// WriteCommitDiff streams the diff of a commit to w without applying output
+// limits. mode selects a merge diff form as in GetMergeDiff and path
+// restricts the diff to one file; both may be empty.
+func WriteCommitDiff(ctx context.Context, repoPath string, w io.Writer, hash, mode, path string) error {
+	commit, err := ResolveRevision(ctx, repoPath, hash)
+	if err != nil {
+		return err
+	}
+	commands, err := diffCommands(commit, mode, path)
+	if err != nil {
+		return err
+	}
+	for i, args := range commands {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n\n"); err != nil {
+				return err
+			}
+		}
+		if err := run(ctx, repoPath, w, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getDiff(ctx context.Context, repoPath, hash, mode, path string) (string, error) {
+	commit, err := ResolveRevision(ctx, repoPath, hash)
+	if err != nil {
+		return "", err
+	}
+	commands, err := diffCommands(commit, mode, path)
+	if err != nil {
+		return "", err
+	}
+
+	limit := currentLimits().MaxDiffBytes
+	diff, err := cached("diff\x00"+commit+"\x00"+mode+"\x00"+path, func() (string, error) {
+		parts := make([]string, 0, len(commands))
+		for _, args := range commands {
+			out, err := commandLimited(ctx, repoPath, limit, args...)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, out)
+		}
+		return strings.Join(parts, "\n\n"), nil
+	}, stringSize)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(diff)) > limit {
+		return "", &TooLargeError{Limit: limit}
+	}
+	return diff, nil
+}
+
+// diffCommands returns the git invocations whose concatenated output is the
+// diff of commit in the given mode, optionally restricted to path.
+func diffCommands(commit, mode, path string) ([][]string, error) {
+	var pathspec []string
+	if path != "" {
+		pathspec = []string{"--", strings.TrimPrefix(path, "/")}
+	}
+
+	switch mode {
+	case "":
+		return [][]string{append([]string{"show", commit}, pathspec...)}, nil
+	case MergeDiffDenseCombined:
+		return [][]string{append([]string{"show", "--cc", commit}, pathspec...)}, nil
+	case MergeDiffCombined:
+		return [][]string{append([]string{"show", "-c", commit}, pathspec...)}, nil
+	case diffModeStash, diffModeStashUntracked:
+		commands := [][]string{
+			{"show", "-s", commit},
+			append([]string{"diff", commit + "^1", commit}, pathspec...),
+		}
+		if mode == diffModeStashUntracked {
+			commands = append(commands, append([]string{"show", "--format=", commit + "^3"}, pathspec...))
+		}
+		return commands, nil
+	}
+
+	parent, err := strconv.Atoi(mode)
+	if err != nil || parent < 1 {
+		return nil, fmt.Errorf("invalid merge diff mode %q", mode)
+	}
+	// The header comes from the merge itself, the patch from the chosen parent.
+	return [][]string{
+		{"show", "-s", commit},
+		append([]string{"diff", fmt.Sprintf("%s^%d", commit, parent), commit}, pathspec...),
+	}, nil
 }
 
 // GetFileHistory returns commit history for a single file.
-func GetFileHistory(repoPath, rev, path string) ([]FileHistoryEntry, error) {
+func GetFileHistory(ctx context.Context, repoPath, rev, path string) ([]FileHistoryEntry, error) {
 	if rev == "" {
 		rev = "HEAD"
 	}
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return nil, err
+	}
 	path = strings.TrimPrefix(path, "/")
 
+	return cached("history\x00"+commit+"\x00"+path, func() ([]FileHistoryEntry, error) {
+		return getFileHistory(ctx, repoPath, commit, path)
+	}, fileHistorySize)
+}
+
+func fileHistorySize(entries []FileHistoryEntry) int64 {
+	size := int64(0)
+	for _, entry := range entries {
+		size += int64(len(entry.Hash)+len(entry.Author)+len(entry.Date)+len(entry.Subject)+len(entry.Path)) + 120
+	}
+	return size
+}
+
+func getFileHistory(ctx context.Context, repoPath, commit, path string) ([]FileHistoryEntry, error) {
 	out, err := Command(
+		ctx,
 		repoPath,
 		"log",
-		rev,
+		commit,
 		"--pretty=format:__GB__%H|%an|%ad|%s",
 		"--date=short",
 		"-n", "50",
@@ -245,24 +603,52 @@ func GetFileHistory(repoPath, rev, path string) ([]FileHistoryEntry, error) {
 }
 
 // GetCommitFileDiff returns diff for a single file in a specific commit.
-func GetCommitFileDiff(repoPath, hash, path string) (string, error) {
-	path = strings.TrimPrefix(path, "/")
-	return Command(repoPath, "show", hash, "--", path)
+func GetCommitFileDiff(ctx context.Context, repoPath, hash, path string) (string, error) {
+	return getDiff(ctx, repoPath, hash, "", path)
 }
 
-// GetCurrentBranch returns the name of the currently checked out branch.
-func GetCurrentBranch(repoPath string) (string, error) {
-	return Command(repoPath, "rev-parse", "--abbrev-ref", "HEAD")
+// GetDefaultBranch returns the branch to show when no revision is given: the
+// branch HEAD points to, or "HEAD" when it is detached. Bare mirrors often have
+// a HEAD naming a branch that does not exist; then the first branch is used.
+func GetDefaultBranch(ctx context.Context, repoPath string) (string, error) {
+	branch, symErr := Command(ctx, repoPath, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if symErr == nil && branch != "" {
+		if _, err := ResolveRevision(ctx, repoPath, branch); err == nil {
+			return branch, nil
+		}
+	} else if _, err := ResolveRevision(ctx, repoPath, "HEAD"); err == nil {
+		return "HEAD", nil
+	}
+
+	branches, err := GetBranches(ctx, repoPath)
+	if err != nil {
+		return "", err
+	}
+	if len(branches) == 0 {
+		return "", fmt.Errorf("%w: repository has no branches", ErrNotFound)
+	}
+	return branches[0], nil
 }
 
-// ValidateRepository checks whether path points to a git working tree.
-func ValidateRepository(repoPath string) error {
-	out, err := Command(repoPath, "rev-parse", "--is-inside-work-tree")
+// ValidateRepository checks whether path points to a git working tree or a
+// bare repository.
+func ValidateRepository(ctx context.Context, repoPath string) error {
+	out, err := Command(ctx, repoPath, "rev-parse", "--is-bare-repository", "--is-inside-work-tree")
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrNotRepository, err)
 	}
-	if out != "true" {
-		return fmt.Errorf("%s is not a git repository", repoPath)
+	bare, inWorkTree, _ := strings.Cut(out, "\n")
+	if bare != "true" && inWorkTree != "true" {
+		return fmt.Errorf("%w: %s", ErrNotRepository, repoPath)
 	}
 	return nil
 }
+
+// IsBareRepository reports whether repoPath is a repository without a work tree.
+func IsBareRepository(ctx context.Context, repoPath string) (bool, error) {
+	out, err := Command(ctx, repoPath, "rev-parse", "--is-bare-repository")
+	if err != nil {
+		return false, err
+	}
+	return out == "true", nil
+}
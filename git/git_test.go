@@ -1,17 +1,20 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetFileHistoryTracksPathAcrossRename(t *testing.T) {
 	repoPath, hashSwitch, hashMove, oldPath, newPath := setupRepoWithRenamedFile(t)
 
-	entries, err := GetFileHistory(repoPath, "HEAD", newPath)
+	entries, err := GetFileHistory(context.Background(), repoPath, "HEAD", newPath)
 	if err != nil {
 		t.Fatalf("GetFileHistory returned error: %v", err)
 	}
@@ -46,7 +49,7 @@ func TestGetFileHistoryTracksPathAcrossRename(t *testing.T) {
 func TestGetCommitFileDiffRequiresPathAtThatCommit(t *testing.T) {
 	repoPath, hashSwitch, _, oldPath, newPath := setupRepoWithRenamedFile(t)
 
-	emptyDiff, err := GetCommitFileDiff(repoPath, hashSwitch, newPath)
+	emptyDiff, err := GetCommitFileDiff(context.Background(), repoPath, hashSwitch, newPath)
 	if err != nil {
 		t.Fatalf("GetCommitFileDiff returned error for new path: %v", err)
 	}
@@ -54,7 +57,7 @@ func TestGetCommitFileDiffRequiresPathAtThatCommit(t *testing.T) {
 		t.Fatalf("expected empty diff for path that did not exist at commit, got %q", emptyDiff)
 	}
 
-	diff, err := GetCommitFileDiff(repoPath, hashSwitch, oldPath)
+	diff, err := GetCommitFileDiff(context.Background(), repoPath, hashSwitch, oldPath)
 	if err != nil {
 		t.Fatalf("GetCommitFileDiff returned error for historical path: %v", err)
 	}
@@ -66,6 +69,94 @@ func TestGetCommitFileDiffRequiresPathAtThatCommit(t *testing.T) {
 	}
 }
 
+func TestGetMergeDiffAgainstEachParent(t *testing.T) {
+	repoPath, merge := setupRepoWithMerge(t)
+
+	parents, err := GetCommitParents(context.Background(), repoPath, merge)
+	if err != nil {
+		t.Fatalf("GetCommitParents returned error: %v", err)
+	}
+	if len(parents) != 2 {
+		t.Fatalf("expected merge commit to have 2 parents, got %d", len(parents))
+	}
+
+	againstFirst, err := GetMergeDiff(context.Background(), repoPath, merge, "1")
+	if err != nil {
+		t.Fatalf("GetMergeDiff against parent 1 returned error: %v", err)
+	}
+	if !strings.Contains(againstFirst, "+feature") || strings.Contains(againstFirst, "+main") {
+		t.Fatalf("expected diff against first parent to only add feature.txt, got %q", againstFirst)
+	}
+
+	againstSecond, err := GetMergeDiff(context.Background(), repoPath, merge, "2")
+	if err != nil {
+		t.Fatalf("GetMergeDiff against parent 2 returned error: %v", err)
+	}
+	if !strings.Contains(againstSecond, "+main") || strings.Contains(againstSecond, "+feature") {
+		t.Fatalf("expected diff against second parent to only add main.txt, got %q", againstSecond)
+	}
+
+	if _, err := GetMergeDiff(context.Background(), repoPath, merge, "combined"); err != nil {
+		t.Fatalf("GetMergeDiff combined returned error: %v", err)
+	}
+	if _, err := GetMergeDiff(context.Background(), repoPath, merge, "0"); err == nil {
+		t.Fatalf("expected error for invalid parent number")
+	}
+}
+
+func TestCommandReportsTimeout(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFile(t)
+
+	SetCommandTimeout(time.Nanosecond)
+	defer SetCommandTimeout(DefaultCommandTimeout)
+
+	if _, err := GetFileHistory(context.Background(), repoPath, "HEAD", newPath); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout from GetFileHistory, got %v", err)
+	}
+	if _, err := GetFileContent(context.Background(), repoPath, "HEAD", newPath); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout from GetFileContent, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	SetCommandTimeout(DefaultCommandTimeout)
+	if _, err := GetFileHistory(ctx, repoPath, "HEAD", newPath); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled for canceled request, got %v", err)
+	}
+}
+
+func setupRepoWithMerge(t *testing.T) (repoPath, merge string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found")
+	}
+
+	repoPath = t.TempDir()
+	runGit(t, repoPath, "init", "-b", "main")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	runGit(t, repoPath, "config", "user.email", "test@example.com")
+
+	writeFile(t, filepath.Join(repoPath, "README"), "base\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "base")
+
+	runGit(t, repoPath, "checkout", "-b", "feature")
+	writeFile(t, filepath.Join(repoPath, "feature.txt"), "feature\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "add feature")
+
+	runGit(t, repoPath, "checkout", "main")
+	writeFile(t, filepath.Join(repoPath, "main.txt"), "main\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "add main")
+
+	runGit(t, repoPath, "merge", "--no-ff", "-m", "merge feature", "feature")
+	merge = runGit(t, repoPath, "rev-parse", "HEAD")
+
+	return repoPath, merge
+}
+
 func setupRepoWithRenamedFile(t *testing.T) (repoPath, hashSwitch, hashMove, oldPath, newPath string) {
 	t.Helper()
 
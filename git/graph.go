@@ -0,0 +1,110 @@
+package git
+
+// GraphRow describes how a single log entry is drawn in the commit graph.
+// Lanes are vertical tracks numbered from the left; each row is split into a
+// top half (lines arriving from the row above) and a bottom half (lines
+// leaving towards the row below).
+type GraphRow struct {
+	Column int
+	Color  int
+	Width  int
+	Edges  []GraphEdge
+}
+
+// GraphEdge is a line within a row. FromY and ToY are 0 for the top of the
+// row, 1 for the commit node and 2 for the bottom of the row.
+type GraphEdge struct {
+	FromLane int
+	FromY    int
+	ToLane   int
+	ToY      int
+	Color    int
+}
+
+// BuildGraph assigns lanes to log entries using their parent hashes. Entries
+// must be ordered with children before parents, as produced by GetLog.
+func BuildGraph(entries []LogEntry) []GraphRow {
+	var lanes []string // commit hash expected next in each lane, "" when free
+	var colors []int
+	nextColor := 0
+
+	claimLane := func() int {
+		for i, hash := range lanes {
+			if hash == "" {
+				colors[i] = nextColor
+				nextColor++
+				return i
+			}
+		}
+		lanes = append(lanes, "")
+		colors = append(colors, nextColor)
+		nextColor++
+		return len(lanes) - 1
+	}
+
+	rows := make([]GraphRow, 0, len(entries))
+	for _, entry := range entries {
+		column := -1
+		for i, hash := range lanes {
+			if hash == entry.Hash {
+				column = i
+				break
+			}
+		}
+		if column < 0 {
+			// Nothing above points at this commit, so it starts a new line.
+			column = claimLane()
+		}
+
+		var edges []GraphEdge
+		for i, hash := range lanes {
+			switch hash {
+			case "":
+			case entry.Hash:
+				edges = append(edges, GraphEdge{FromLane: i, FromY: 0, ToLane: column, ToY: 1, Color: colors[i]})
+				lanes[i] = ""
+			default:
+				edges = append(edges, GraphEdge{FromLane: i, FromY: 0, ToLane: i, ToY: 2, Color: colors[i]})
+			}
+		}
+		row := GraphRow{Column: column, Color: colors[column]}
+
+		for i, parent := range entry.Parents {
+			target := -1
+			for lane, hash := range lanes {
+				if hash == parent {
+					target = lane
+					break
+				}
+			}
+			if target < 0 {
+				if i == 0 && lanes[column] == "" {
+					target = column
+				} else {
+					target = claimLane()
+				}
+				lanes[target] = parent
+			}
+			edges = append(edges, GraphEdge{FromLane: column, FromY: 1, ToLane: target, ToY: 2, Color: colors[target]})
+		}
+
+		row.Edges = edges
+		row.Width = column + 1
+		for _, edge := range edges {
+			if edge.FromLane+1 > row.Width {
+				row.Width = edge.FromLane + 1
+			}
+			if edge.ToLane+1 > row.Width {
+				row.Width = edge.ToLane + 1
+			}
+		}
+		rows = append(rows, row)
+
+		for len(lanes) > 0 && lanes[len(lanes)-1] == "" {
+			lanes = lanes[:len(lanes)-1]
+			colors = colors[:len(colors)-1]
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,43 @@
+package git
+
+import "testing"
+
+func TestBuildGraphPlacesMergedBranchInSecondLane(t *testing.T) {
+	// merge <- main <- base, merge <- feature <- base
+	entries := []LogEntry{
+		{Hash: "merge", Parents: []string{"main", "feature"}},
+		{Hash: "main", Parents: []string{"base"}},
+		{Hash: "feature", Parents: []string{"base"}},
+		{Hash: "base"},
+	}
+
+	rows := BuildGraph(entries)
+	if len(rows) != len(entries) {
+		t.Fatalf("expected %d rows, got %d", len(entries), len(rows))
+	}
+
+	wantColumns := []int{0, 0, 1, 0}
+	wantWidths := []int{2, 2, 2, 1}
+	for i, row := range rows {
+		if row.Column != wantColumns[i] {
+			t.Fatalf("row %d column mismatch: got %d want %d", i, row.Column, wantColumns[i])
+		}
+		if row.Width != wantWidths[i] {
+			t.Fatalf("row %d width mismatch: got %d want %d", i, row.Width, wantWidths[i])
+		}
+	}
+
+	// The feature commit's lane must converge into base's lane.
+	var converges bool
+	for _, edge := range rows[2].Edges {
+		if edge.FromLane == 1 && edge.FromY == 1 && edge.ToLane == 0 && edge.ToY == 2 {
+			converges = true
+		}
+	}
+	if !converges {
+		t.Fatalf("expected feature commit to connect back to lane 0, got edges %+v", rows[2].Edges)
+	}
+	if rows[0].Color == rows[2].Color {
+		t.Fatalf("expected merged branch to use a different color than main")
+	}
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LastCommit is the most recent commit that touched a tree entry.
+type LastCommit struct {
+	Hash    string
+	Subject string
+	Time    time.Time
+}
+
+// GetLastCommits returns the most recent commit touching each of the given
+// entry names in the directory at rev and path. History is walked once for
+// the whole directory, and results are cached per commit and tree hash.
+func GetLastCommits(ctx context.Context, repoPath, rev, path string, names []string) (map[string]LastCommit, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	path = strings.Trim(path, "/")
+
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return nil, err
+	}
+	treeSpec := commit + "^{tree}"
+	if path != "" {
+		treeSpec = commit + ":" + path
+	}
+	treeInfo, err := statObject(ctx, repoPath, treeSpec)
+	if err != nil {
+		return nil, err
+	}
+	tree := treeInfo.Hash
+
+	return cached("last\x00"+commit+"\x00"+tree, func() (map[string]LastCommit, error) {
+		return walkLastCommits(ctx, repoPath, commit, path, names)
+	}, lastCommitsSize)
+}
+
+func lastCommitsSize(commits map[string]LastCommit) int64 {
+	size := int64(0)
+	for name, commit := range commits {
+		size += int64(len(name)+len(commit.Hash)+len(commit.Subject)) + 100
+	}
+	return size
+}
+
+// walkLastCommits streams the directory history and stops reading as soon as
+// every entry has been attributed to a commit.
+func walkLastCommits(ctx context.Context, repoPath, commit, path string, names []string) (map[string]LastCommit, error) {
+	pending := make(map[string]bool, len(names))
+	for _, name := range names {
+		pending[name] = true
+	}
+	result := make(map[string]LastCommit, len(names))
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	prefix := ""
+	args := []string{"log", "-z", commit, "--pretty=format:__GB__%H|%ct|%s", "--name-only", "--no-renames"}
+	if path != "" {
+		prefix = path + "/"
+		args = append(args, "--", path)
+	}
+
+	release, err := acquireProcess(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := withCommandTimeout(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var current LastCommit
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(scanNul)
+	for len(pending) > 0 && scanner.Scan() {
+		// With -z, names are NUL-terminated and never quoted. A commit header
+		// is followed by a newline and the first of its names, if any.
+		line := scanner.Text()
+		if header, ok := strings.CutPrefix(line, "__GB__"); ok {
+			header, line, _ = strings.Cut(header, "\n")
+			parts := strings.SplitN(header, "|", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			seconds, _ := strconv.ParseInt(parts[1], 10, 64)
+			current = LastCommit{Hash: parts[0], Subject: parts[2], Time: time.Unix(seconds, 0)}
+		}
+		if current.Hash == "" || line == "" || !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(line, prefix), "/")
+		if pending[name] {
+			result[name] = current
+			delete(pending, name)
+		}
+	}
+
+	if len(pending) == 0 {
+		// Everything is attributed; the rest of the history is not needed.
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return result, nil
+	}
+	if err := scanner.Err(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if ctxErr := contextError(ctx, args); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctxErr := contextError(ctx, args); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanNul is a bufio.SplitFunc for NUL-terminated records.
+func scanNul(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetLastCommitsAttributesEachEntry(t *testing.T) {
+	repoPath, hashSwitch, hashMove, _, _ := setupRepoWithRenamedFile(t)
+
+	writeFile(t, filepath.Join(repoPath, "README.md"), "readme\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "add readme")
+	hashReadme := runGit(t, repoPath, "rev-parse", "HEAD")
+
+	root, err := GetLastCommits(context.Background(), repoPath, "HEAD", "", []string{"Android", "README.md"})
+	if err != nil {
+		t.Fatalf("GetLastCommits returned error: %v", err)
+	}
+	if root["Android"].Hash != hashMove {
+		t.Fatalf("Android last commit mismatch: got %q want %q", root["Android"].Hash, hashMove)
+	}
+	if root["README.md"].Hash != hashReadme {
+		t.Fatalf("README.md last commit mismatch: got %q want %q", root["README.md"].Hash, hashReadme)
+	}
+	if root["README.md"].Subject != "add readme" {
+		t.Fatalf("README.md subject mismatch: got %q", root["README.md"].Subject)
+	}
+
+	// At the older revision the directory did not exist under Android/ yet.
+	old, err := GetLastCommits(context.Background(), repoPath, hashSwitch, "androidApp", []string{"src"})
+	if err != nil {
+		t.Fatalf("GetLastCommits at older revision returned error: %v", err)
+	}
+	if old["src"].Hash != hashSwitch {
+		t.Fatalf("src last commit mismatch: got %q want %q", old["src"].Hash, hashSwitch)
+	}
+}
+
+func TestGetLastCommitsMatchesNamesGitWouldQuote(t *testing.T) {
+	repoPath, _, hashMove, _, _ := setupRepoWithRenamedFile(t)
+
+	names := []string{`say "hi".txt`, `back\slash`, "tab\tname", "ünïcode"}
+	for _, name := range names {
+		writeFile(t, filepath.Join(repoPath, "docs", name), name+"\n")
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-m", "add odd names")
+	hashDocs := runGit(t, repoPath, "rev-parse", "HEAD")
+
+	docs, err := GetLastCommits(context.Background(), repoPath, "HEAD", "docs", names)
+	if err != nil {
+		t.Fatalf("GetLastCommits returned error: %v", err)
+	}
+	for _, name := range names {
+		if docs[name].Hash != hashDocs {
+			t.Fatalf("%q last commit mismatch: got %q want %q", name, docs[name].Hash, hashDocs)
+		}
+	}
+	root, err := GetLastCommits(context.Background(), repoPath, "HEAD", "", []string{"Android", "docs"})
+	if err != nil || root["Android"].Hash != hashMove || root["docs"].Hash != hashDocs {
+		t.Fatalf("unexpected root last commits %+v, %v", root, err)
+	}
+}
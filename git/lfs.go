@@ -0,0 +1,174 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxLFSPointerSize is the largest blob read to check for an LFS pointer.
+// Pointer files are well below it.
+const maxLFSPointerSize = 1024
+
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// LFSPointer is a Git LFS pointer file standing in for the real content.
+type LFSPointer struct {
+	// OID is the SHA-256 of the content, without the "sha256:" prefix.
+	OID  string
+	Size int64
+	// Local is set when the content is in the repository's local LFS store.
+	Local bool
+}
+
+// GetLFSPointer returns the LFS pointer stored in file, as returned by
+// StatFile, or nil if the file is not one.
+func GetLFSPointer(ctx context.Context, repoPath string, file FileInfo) (*LFSPointer, error) {
+	if file.IsSymlink() || file.Size > maxLFSPointerSize {
+		return nil, nil
+	}
+	_, content, err := readObject(ctx, repoPath, file.Hash)
+	if err != nil {
+		return nil, err
+	}
+	pointer := parseLFSPointer(string(content))
+	if pointer == nil {
+		return nil, nil
+	}
+
+	objectPath, err := lfsObjectPath(ctx, repoPath, pointer.OID)
+	if err != nil {
+		return nil, err
+	}
+	if stat, err := os.Stat(objectPath); err == nil && stat.Mode().IsRegular() && stat.Size() == pointer.Size {
+		pointer.Local = true
+	}
+	return pointer, nil
+}
+
+// parseLFSPointer parses the content of a pointer file: "key value" lines
+// starting with the version, sorted by key, with a sha256 oid and a size.
+func parseLFSPointer(content string) *LFSPointer {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(lines) < 3 || lines[0] != lfsPointerVersion {
+		return nil
+	}
+	var pointer LFSPointer
+	sizeSet := false
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil
+		}
+		switch key {
+		case "oid":
+			oid, ok := strings.CutPrefix(value, "sha256:")
+			if !ok || !isSHA256(oid) {
+				return nil
+			}
+			pointer.OID = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil
+			}
+			pointer.Size, sizeSet = size, true
+		}
+	}
+	if pointer.OID == "" || !sizeSet {
+		return nil
+	}
+	return &pointer
+}
+
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// commonDirs remembers the git common directory of each repository path.
+var commonDirs sync.Map
+
+// ForgetCommonDir drops the remembered git common directory of a repository,
+// e.g. after it was removed from the configuration.
+func ForgetCommonDir(repoPath string) {
+	commonDirs.Delete(repoPath)
+}
+
+// lfsObjectPath returns where the local LFS store keeps the object oid. The
+// store is shared by all worktrees of a repository.
+func lfsObjectPath(ctx context.Context, repoPath, oid string) (string, error) {
+	commonDir, ok := commonDirs.Load(repoPath)
+	if !ok {
+		dir, err := Command(ctx, repoPath, "rev-parse", "--git-common-dir")
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(repoPath, dir)
+		}
+		commonDir, _ = commonDirs.LoadOrStore(repoPath, dir)
+	}
+	return filepath.Join(commonDir.(string), "lfs", "objects", oid[:2], oid[2:4], oid), nil
+}
+
+// GetLFSContent returns the content of a local LFS object, or a
+// *TooLargeError if it exceeds MaxBlobBytes. Only content that matches the
+// pointer is cached.
+func GetLFSContent(ctx context.Context, repoPath string, pointer LFSPointer) (string, error) {
+	if limit := currentLimits().MaxBlobBytes; pointer.Size > limit {
+		return "", &TooLargeError{Limit: limit}
+	}
+	return cached("lfs\x00"+pointer.OID, func() (string, error) {
+		var content strings.Builder
+		if err := WriteLFSContent(ctx, repoPath, &content, pointer); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(content.String(), "\n"), nil
+	}, stringSize)
+}
+
+// WriteLFSContent streams the content of a local LFS object to w. The size and
+// SHA-256 are checked while copying; a mismatch is only reported after the
+// content was written, so callers that stream it must abort the response.
+func WriteLFSContent(ctx context.Context, repoPath string, w io.Writer, pointer LFSPointer) error {
+	if !isSHA256(pointer.OID) {
+		return fmt.Errorf("%w: invalid LFS object id %q", ErrNotFound, pointer.OID)
+	}
+	objectPath, err := lfsObjectPath(ctx, repoPath, pointer.OID)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(objectPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: LFS object %s is not in the local store", ErrNotFound, pointer.OID)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), io.LimitReader(f, pointer.Size+1))
+	if err != nil {
+		return err
+	}
+	if n != pointer.Size || hex.EncodeToString(hash.Sum(nil)) != pointer.OID {
+		return fmt.Errorf("LFS object %s does not match its pointer", pointer.OID)
+	}
+	return nil
+}
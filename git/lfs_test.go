@@ -0,0 +1,90 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLFSPointerResolvesLocalObjects(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	content := "large design file\n"
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+	pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(content))
+	missing := strings.Repeat("0", 64)
+	writeFile(t, filepath.Join(repoPath, "design.psd"), pointer)
+	writeFile(t, filepath.Join(repoPath, "missing.psd"), fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize 12\n", missing))
+	writeFile(t, filepath.Join(repoPath, "fake.txt"), "version https://git-lfs.github.com/spec/v1\noid sha256:../../config\nsize 1\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-q", "-m", "add lfs files")
+	writeFile(t, filepath.Join(repoPath, ".git", "lfs", "objects", oid[:2], oid[2:4], oid), content)
+
+	got, err := lfsPointerAt(t, repoPath, "design.psd")
+	if err != nil {
+		t.Fatalf("GetLFSPointer returned error: %v", err)
+	}
+	if got == nil || *got != (LFSPointer{OID: oid, Size: int64(len(content)), Local: true}) {
+		t.Fatalf("unexpected pointer: %+v", got)
+	}
+	text, err := GetLFSContent(ctx, repoPath, *got)
+	if err != nil || text != strings.TrimSuffix(content, "\n") {
+		t.Fatalf("unexpected LFS content %q, %v", text, err)
+	}
+	var raw bytes.Buffer
+	if err := WriteLFSContent(ctx, repoPath, &raw, *got); err != nil || raw.String() != content {
+		t.Fatalf("unexpected raw LFS content %q, %v", raw.String(), err)
+	}
+
+	// A damaged object is rejected and its content not cached.
+	damaged := LFSPointer{OID: strings.Repeat("b", 64), Size: int64(len(content)), Local: true}
+	writeFile(t, filepath.Join(repoPath, ".git", "lfs", "objects", "bb", "bb", damaged.OID), content)
+	if _, err := GetLFSContent(ctx, repoPath, damaged); err == nil {
+		t.Fatalf("expected an error for content not matching its pointer")
+	}
+	if err := WriteLFSContent(ctx, repoPath, &raw, LFSPointer{OID: oid, Size: int64(len(content)) - 1}); err == nil {
+		t.Fatalf("expected an error for content larger than its pointer says")
+	}
+	if _, ok := cache.get("lfs\x00" + damaged.OID); ok {
+		t.Fatalf("expected damaged LFS content not to be cached")
+	}
+
+	got, err = lfsPointerAt(t, repoPath, "missing.psd")
+	if err != nil || got == nil || got.OID != missing || got.Local {
+		t.Fatalf("expected pointer without local object, got %+v, %v", got, err)
+	}
+	if err := WriteLFSContent(ctx, repoPath, &raw, *got); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing object, got %v", err)
+	}
+
+	for _, path := range []string{"fake.txt", newPath} {
+		if got, err := lfsPointerAt(t, repoPath, path); err != nil || got != nil {
+			t.Fatalf("%s: expected no LFS pointer, got %+v, %v", path, got, err)
+		}
+	}
+
+	if _, ok := commonDirs.Load(repoPath); !ok {
+		t.Fatalf("expected the common dir to be remembered")
+	}
+	ForgetCommonDir(repoPath)
+	if _, ok := commonDirs.Load(repoPath); ok {
+		t.Fatalf("expected the common dir to be forgotten")
+	}
+}
+
+func lfsPointerAt(t *testing.T, repoPath, path string) (*LFSPointer, error) {
+	t.Helper()
+	file, err := StatFile(context.Background(), repoPath, "HEAD", path)
+	if err != nil {
+		t.Fatalf("StatFile %s returned error: %v", path, err)
+	}
+	return GetLFSPointer(context.Background(), repoPath, file)
+}
@@ -0,0 +1,148 @@
+package git
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Concurrency bounds the number of git subprocesses running at once.
+type Concurrency struct {
+	// MaxProcesses caps processes across all repositories.
+	MaxProcesses int
+	// MaxProcessesPerRepo caps processes for a single repository.
+	MaxProcessesPerRepo int
+	// QueueTimeout is how long a command may wait for a free slot before
+	// failing with ErrBusy.
+	QueueTimeout time.Duration
+}
+
+// DefaultConcurrency is used until SetConcurrency is called.
+var DefaultConcurrency = Concurrency{
+	MaxProcesses:        32,
+	MaxProcessesPerRepo: 8,
+	QueueTimeout:        5 * time.Second,
+}
+
+// Metrics are published through expvar under "git".
+var metrics = expvar.NewMap("git")
+
+var (
+	queueDepth   = new(expvar.Int)
+	running      = new(expvar.Int)
+	rejected     = new(expvar.Int)
+	queueWaitSum = new(expvar.Float)
+)
+
+// Metrics returns the process limiter metrics as a JSON object.
+func Metrics() string {
+	return metrics.String()
+}
+
+func init() {
+	metrics.Set("queue_depth", queueDepth)
+	metrics.Set("running", running)
+	metrics.Set("rejected_total", rejected)
+	metrics.Set("queue_wait_seconds_total", queueWaitSum)
+}
+
+type limiter struct {
+	config Concurrency
+	global chan struct{}
+
+	mu     sync.Mutex
+	byRepo map[string]chan struct{}
+}
+
+var (
+	limiterMu     sync.RWMutex
+	activeLimiter = newLimiter(DefaultConcurrency)
+)
+
+func newLimiter(c Concurrency) *limiter {
+	return &limiter{
+		config: c,
+		global: make(chan struct{}, c.MaxProcesses),
+		byRepo: make(map[string]chan struct{}),
+	}
+}
+
+// SetConcurrency replaces the process limits. Zero fields keep their default
+// value. Commands already running keep the slots they hold, so the limits are
+// only replaced when they actually change; otherwise a config reload would let
+// twice as many processes run.
+func SetConcurrency(c Concurrency) {
+	if c.MaxProcesses <= 0 {
+		c.MaxProcesses = DefaultConcurrency.MaxProcesses
+	}
+	if c.MaxProcessesPerRepo <= 0 {
+		c.MaxProcessesPerRepo = DefaultConcurrency.MaxProcessesPerRepo
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = DefaultConcurrency.QueueTimeout
+	}
+
+	limiterMu.Lock()
+	if activeLimiter.config != c {
+		activeLimiter = newLimiter(c)
+	}
+	limiterMu.Unlock()
+}
+
+// acquireProcess waits for a free slot for repoPath. The returned function
+// releases it and must be called once the process has exited.
+func acquireProcess(ctx context.Context, repoPath string) (func(), error) {
+	limiterMu.RLock()
+	l := activeLimiter
+	limiterMu.RUnlock()
+
+	l.mu.Lock()
+	repoSlots, ok := l.byRepo[repoPath]
+	if !ok {
+		repoSlots = make(chan struct{}, l.config.MaxProcessesPerRepo)
+		l.byRepo[repoPath] = repoSlots
+	}
+	l.mu.Unlock()
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.config.QueueTimeout)
+	defer cancel()
+
+	start := time.Now()
+	queueDepth.Add(1)
+	defer func() {
+		queueDepth.Add(-1)
+		queueWaitSum.Add(time.Since(start).Seconds())
+	}()
+
+	// Take the repository slot first so a busy repository does not hold
+	// global slots while it waits.
+	if err := l.takeSlot(waitCtx, ctx, repoSlots); err != nil {
+		return nil, err
+	}
+	if err := l.takeSlot(waitCtx, ctx, l.global); err != nil {
+		<-repoSlots
+		return nil, err
+	}
+
+	running.Add(1)
+	return func() {
+		running.Add(-1)
+		<-l.global
+		<-repoSlots
+	}, nil
+}
+
+func (l *limiter) takeSlot(waitCtx, ctx context.Context, slots chan struct{}) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-waitCtx.Done():
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rejected.Add(1)
+		return fmt.Errorf("%w: no free slot within %s", ErrBusy, l.config.QueueTimeout)
+	}
+}
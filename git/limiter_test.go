@@ -0,0 +1,59 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommandFailsWithErrBusyWhenSaturated(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	SetConcurrency(Concurrency{MaxProcesses: 1, MaxProcessesPerRepo: 1, QueueTimeout: 50 * time.Millisecond})
+	defer SetConcurrency(DefaultConcurrency)
+
+	release, err := acquireProcess(ctx, repoPath)
+	if err != nil {
+		t.Fatalf("acquireProcess returned error: %v", err)
+	}
+
+	if _, err := Command(ctx, repoPath, "rev-parse", "HEAD"); !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected ErrBusy while the only slot is taken, got %v", err)
+	}
+	// Revisions resolve through the persistent cat-file process and need no
+	// slot of their own.
+	if _, err := ResolveRevision(ctx, repoPath, "HEAD"); err != nil {
+		t.Fatalf("expected ResolveRevision to work while the only slot is taken, got %v", err)
+	}
+	if depth := queueDepth.Value(); depth != 0 {
+		t.Fatalf("expected empty queue after rejection, got depth %d", depth)
+	}
+
+	release()
+	if _, err := Command(ctx, repoPath, "rev-parse", "HEAD"); err != nil {
+		t.Fatalf("Command after release returned error: %v", err)
+	}
+}
+
+func TestSetConcurrencyWithUnchangedLimitsKeepsHeldSlots(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	limits := Concurrency{MaxProcesses: 1, MaxProcessesPerRepo: 1, QueueTimeout: 50 * time.Millisecond}
+	SetConcurrency(limits)
+	defer SetConcurrency(DefaultConcurrency)
+
+	release, err := acquireProcess(ctx, repoPath)
+	if err != nil {
+		t.Fatalf("acquireProcess returned error: %v", err)
+	}
+	defer release()
+
+	// A config reload applies the same limits again.
+	SetConcurrency(limits)
+	if _, err := Command(ctx, repoPath, "rev-parse", "HEAD"); !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected the held slot to still count after reapplying the limits, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+)
+
+// Limits caps how much output is buffered in memory for a single request.
+// Streaming functions such as WriteCommitDiff and WriteFileContent are not
+// subject to them.
+type Limits struct {
+	// MaxBlobBytes caps objects read for display, such as file contents.
+	MaxBlobBytes int64
+	// MaxDiffBytes caps commit and file diffs.
+	MaxDiffBytes int64
+	// MaxLogBytes caps every other command, such as logs and branch lists.
+	MaxLogBytes int64
+}
+
+// DefaultLimits are used until SetLimits is called.
+var DefaultLimits = Limits{
+	MaxBlobBytes: 5 << 20,
+	MaxDiffBytes: 5 << 20,
+	MaxLogBytes:  4 << 20,
+}
+
+var limits atomic.Pointer[Limits]
+
+func init() {
+	l := DefaultLimits
+	limits.Store(&l)
+}
+
+// SetLimits replaces the output limits. Zero fields keep their default value.
+func SetLimits(l Limits) {
+	if l.MaxBlobBytes <= 0 {
+		l.MaxBlobBytes = DefaultLimits.MaxBlobBytes
+	}
+	if l.MaxDiffBytes <= 0 {
+		l.MaxDiffBytes = DefaultLimits.MaxDiffBytes
+	}
+	if l.MaxLogBytes <= 0 {
+		l.MaxLogBytes = DefaultLimits.MaxLogBytes
+	}
+	limits.Store(&l)
+}
+
+func currentLimits() Limits {
+	return *limits.Load()
+}
+
+// TooLargeError is returned when output exceeds one of the configured Limits.
+type TooLargeError struct {
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("output exceeds the limit of %d bytes", e.Limit)
+}
+
+// Is makes TooLargeError match ErrTooLarge.
+func (e *TooLargeError) Is(target error) bool {
+	return target == ErrTooLarge
+}
+
+// limitedBuffer collects up to limit bytes and fails every write after that,
+// which closes the pipe and stops the git process writing to it. The buffer
+// is not embedded, so io.Copy cannot bypass Write through its ReadFrom.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - int64(b.buf.Len()); int64(len(p)) > room {
+		b.exceeded = true
+		n, _ := b.buf.Write(p[:max(room, 0)])
+		return n, &TooLargeError{Limit: b.limit}
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
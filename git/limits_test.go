@@ -0,0 +1,50 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimitsReportTooLargeAndStreamingBypassesThem(t *testing.T) {
+	repoPath, hashSwitch, _, _, newPath := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	SetLimits(Limits{MaxBlobBytes: 16, MaxDiffBytes: 16, MaxLogBytes: 16})
+	defer SetLimits(DefaultLimits)
+
+	_, err := GetFileContent(ctx, repoPath, "HEAD", newPath)
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 16 {
+		t.Fatalf("expected TooLargeError with limit 16 from GetFileContent, got %v", err)
+	}
+	if _, err := GetCommitDiff(ctx, repoPath, hashSwitch); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge from GetCommitDiff, got %v", err)
+	}
+	if _, err := Command(ctx, repoPath, "log"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge from Command, got %v", err)
+	}
+
+	var content bytes.Buffer
+	if err := WriteFileContent(ctx, repoPath, &content, "HEAD", newPath); err != nil {
+		t.Fatalf("WriteFileContent returned error: %v", err)
+	}
+	if !strings.Contains(content.String(), "toUri") {
+		t.Fatalf("expected streamed content to contain file body, got %q", content.String())
+	}
+	var diff bytes.Buffer
+	if err := WriteCommitDiff(ctx, repoPath, &diff, hashSwitch, "", ""); err != nil {
+		t.Fatalf("WriteCommitDiff returned error: %v", err)
+	}
+	if !strings.Contains(diff.String(), "diff --git") {
+		t.Fatalf("expected streamed diff, got %q", diff.String())
+	}
+
+	// The shared cat-file process must recover after skipping an oversized object.
+	SetLimits(DefaultLimits)
+	if _, err := GetFileContent(ctx, repoPath, "HEAD", newPath); err != nil {
+		t.Fatalf("GetFileContent after raising limits returned error: %v", err)
+	}
+}
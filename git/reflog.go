@@ -0,0 +1,219 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReflogEntry is one update of a ref as recorded in its reflog.
+type ReflogEntry struct {
+	// Selector names the entry, e.g. "HEAD@{0}".
+	Selector string
+	// Old and New are the ref values before and after the update. Old is
+	// empty when the ref was created, New when it was deleted.
+	Old string
+	New string
+	// Action is the kind of update, e.g. "commit", "checkout" or
+	// "rebase (finish)", and Message the rest of the reflog message.
+	Action  string
+	Message string
+	Time    time.Time
+}
+
+// ReflogResult holds the entries of a reflog, newest first.
+type ReflogResult struct {
+	// Ref is the full name of the ref, e.g. "refs/heads/main" or "HEAD".
+	Ref     string
+	Entries []ReflogEntry
+	// Truncated is set when older entries were left out because the reflog
+	// exceeds MaxLogBytes.
+	Truncated bool
+}
+
+// GetReflog returns the reflog of ref, which may be "HEAD", a short name like
+// "main" or a full ref name. The reflog file is read directly, since git's log
+// formats cannot show the previous value of each entry. Repositories that keep
+// refs in a reftable have no such file; their reflog is read with "git log -g"
+// instead.
+func GetReflog(ctx context.Context, repoPath, ref string) (ReflogResult, error) {
+	var result ReflogResult
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, "\x00\r\n") {
+		return result, &UnknownRevisionError{Rev: ref}
+	}
+
+	// rev-parse would resolve HEAD to the branch it points to.
+	result.Ref = ref
+	if ref != "HEAD" {
+		full, err := Command(ctx, repoPath, "rev-parse", "--symbolic-full-name", ref)
+		if err != nil || !strings.HasPrefix(full, "refs/") || strings.Contains(full, "\n") {
+			if err != nil && ctx.Err() != nil {
+				return result, err
+			}
+			return result, &UnknownRevisionError{Rev: ref}
+		}
+		result.Ref = full
+	}
+
+	storage, err := Command(ctx, repoPath, "config", "--get", "extensions.refStorage")
+	if err != nil && ctx.Err() != nil {
+		return result, err
+	}
+	if storage != "" && storage != "files" {
+		return walkReflog(ctx, repoPath, result)
+	}
+
+	logPath, err := Command(ctx, repoPath, "rev-parse", "--git-path", "logs/"+result.Ref)
+	if err != nil {
+		return result, err
+	}
+	if !filepath.IsAbs(logPath) {
+		logPath = filepath.Join(repoPath, logPath)
+	}
+	content, truncated, err := readTail(logPath, currentLimits().MaxLogBytes)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, fmt.Errorf("%w: no reflog for %s", ErrNotFound, result.Ref)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Truncated = truncated
+
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	name := shortRefName(result.Ref)
+	for i := len(lines) - 1; i >= 0; i-- {
+		entry, ok := parseReflogLine(lines[i])
+		if !ok {
+			continue
+		}
+		entry.Selector = fmt.Sprintf("%s@{%d}", name, len(result.Entries))
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+// walkReflog reads the reflog of result.Ref with "git log -g". Old values are
+// taken from the next older entry, so the oldest entry shown has none, and
+// entries pointing to commits that no longer exist are left out.
+func walkReflog(ctx context.Context, repoPath string, result ReflogResult) (ReflogResult, error) {
+	limit := currentLimits().MaxLogBytes
+	stdout := &limitedBuffer{limit: limit}
+	err := run(ctx, repoPath, stdout, "log", "-g", "--date=unix", "--format=%H%x00%gd%x00%gs", result.Ref, "--")
+	out := stdout.String()
+	if stdout.exceeded {
+		// Keep the newest entries, dropping the partial last line.
+		result.Truncated = true
+		out = out[:strings.LastIndexByte(out, '\n')+1]
+	} else if err != nil {
+		if ctx.Err() == nil && strings.Contains(err.Error(), "does not have any commits") {
+			return result, fmt.Errorf("%w: no reflog for %s", ErrNotFound, result.Ref)
+		}
+		return result, err
+	}
+
+	name := shortRefName(result.Ref)
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		entry := ReflogEntry{
+			Selector: fmt.Sprintf("%s@{%d}", name, len(result.Entries)),
+			New:      fields[0],
+		}
+		if i := strings.LastIndex(fields[1], "@{"); i >= 0 {
+			if seconds, err := strconv.ParseInt(strings.TrimSuffix(fields[1][i+2:], "}"), 10, 64); err == nil {
+				entry.Time = time.Unix(seconds, 0)
+			}
+		}
+		if action, rest, ok := strings.Cut(fields[2], ": "); ok {
+			entry.Action, entry.Message = action, rest
+		} else {
+			entry.Message = fields[2]
+		}
+		if n := len(result.Entries); n > 0 {
+			result.Entries[n-1].Old = entry.New
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	if len(result.Entries) == 0 {
+		return result, fmt.Errorf("%w: no reflog for %s", ErrNotFound, result.Ref)
+	}
+	return result, nil
+}
+
+// parseReflogLine parses "<old> <new> <name> <<email>> <time> <tz>\t<message>".
+func parseReflogLine(line string) (ReflogEntry, bool) {
+	var entry ReflogEntry
+	header, message, _ := strings.Cut(line, "\t")
+	fields := strings.Fields(header)
+	if len(fields) < 4 || len(fields[0]) != len(fields[1]) {
+		return entry, false
+	}
+	entry.Old, entry.New = fields[0], fields[1]
+	if strings.Trim(entry.Old, "0") == "" {
+		entry.Old = ""
+	}
+	if strings.Trim(entry.New, "0") == "" {
+		entry.New = ""
+	}
+	if seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64); err == nil {
+		entry.Time = time.Unix(seconds, 0)
+	}
+	if action, rest, ok := strings.Cut(message, ": "); ok {
+		entry.Action, entry.Message = action, rest
+	} else {
+		entry.Message = message
+	}
+	return entry, true
+}
+
+// readTail reads up to limit bytes from the end of a file, starting at a line
+// boundary, and reports whether the beginning was cut off.
+func readTail(path string, limit int64) (string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	offset := info.Size() - limit
+	if offset <= 0 {
+		content, err := io.ReadAll(file)
+		return string(content), false, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return "", false, err
+	}
+	content, err := io.ReadAll(io.LimitReader(file, limit))
+	if err != nil {
+		return "", false, err
+	}
+	// Drop the partial first line.
+	if i := strings.IndexByte(string(content), '\n'); i >= 0 {
+		content = content[i+1:]
+	}
+	return string(content), true, nil
+}
+
+// shortRefName turns "refs/heads/main" into "main", as git shows it in reflog
+// selectors.
+func shortRefName(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/", "refs/"} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			return name
+		}
+	}
+	return ref
+}
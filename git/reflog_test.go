@@ -0,0 +1,91 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetReflogShowsOldAndNewValues(t *testing.T) {
+	repoPath, hashSwitch, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	branch := runGit(t, repoPath, "branch", "--show-current")
+	runGit(t, repoPath, "reset", "-q", "--hard", "HEAD~1")
+	ctx := context.Background()
+
+	reflog, err := GetReflog(ctx, repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("GetReflog returned error: %v", err)
+	}
+	if reflog.Ref != "HEAD" || len(reflog.Entries) != 4 {
+		t.Fatalf("unexpected reflog: %+v", reflog)
+	}
+	latest := reflog.Entries[0]
+	if latest.Selector != "HEAD@{0}" || latest.Old != hashMove || latest.New != hashSwitch || latest.Action != "reset" || latest.Message != "moving to HEAD~1" || latest.Time.IsZero() {
+		t.Fatalf("unexpected latest entry: %+v", latest)
+	}
+	first := reflog.Entries[3]
+	if first.Old != "" || first.Action != "commit (initial)" {
+		t.Fatalf("unexpected first entry: %+v", first)
+	}
+
+	branchLog, err := GetReflog(ctx, repoPath, branch)
+	if err != nil || branchLog.Ref != "refs/heads/"+branch || branchLog.Entries[0].Selector != branch+"@{0}" {
+		t.Fatalf("unexpected branch reflog: %+v, %v", branchLog, err)
+	}
+
+	for _, ref := range []string{"does-not-exist", "--output=/tmp/x", hashMove} {
+		if _, err := GetReflog(ctx, repoPath, ref); !errors.Is(err, ErrBadRevision) {
+			t.Fatalf("GetReflog(%q): expected ErrBadRevision, got %v", ref, err)
+		}
+	}
+}
+
+func TestGetReflogKeepsNewestEntriesWhenTruncated(t *testing.T) {
+	repoPath, _, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	SetLimits(Limits{MaxLogBytes: 200})
+	defer SetLimits(Limits{})
+
+	reflog, err := GetReflog(context.Background(), repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("GetReflog returned error: %v", err)
+	}
+	if !reflog.Truncated || len(reflog.Entries) == 0 || len(reflog.Entries) >= 3 || reflog.Entries[0].New != hashMove {
+		t.Fatalf("unexpected truncated reflog: %+v", reflog)
+	}
+}
+
+func TestGetReflogWithoutReflogFilesUsesLog(t *testing.T) {
+	repoPath, hashSwitch, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	runGit(t, repoPath, "reset", "-q", "--hard", "HEAD~1")
+	ctx := context.Background()
+
+	want, err := GetReflog(ctx, repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("GetReflog returned error: %v", err)
+	}
+	// Git versions without reftable support ignore the extension in a
+	// version 0 repository, so the reflog can still be walked.
+	runGit(t, repoPath, "config", "extensions.refStorage", "reftable")
+	got, err := GetReflog(ctx, repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("GetReflog returned error: %v", err)
+	}
+	if len(got.Entries) != len(want.Entries) {
+		t.Fatalf("unexpected reflog: got %+v want %+v", got, want)
+	}
+	for i, entry := range got.Entries {
+		if entry != want.Entries[i] {
+			t.Fatalf("entry %d: got %+v want %+v", i, entry, want.Entries[i])
+		}
+	}
+	if latest := got.Entries[0]; latest.Old != hashMove || latest.New != hashSwitch {
+		t.Fatalf("unexpected latest entry: %+v", latest)
+	}
+
+	SetLimits(Limits{MaxLogBytes: 150})
+	defer SetLimits(Limits{})
+	truncated, err := GetReflog(ctx, repoPath, "HEAD")
+	if err != nil || !truncated.Truncated || len(truncated.Entries) == 0 || len(truncated.Entries) >= len(want.Entries) || truncated.Entries[0].New != hashSwitch {
+		t.Fatalf("unexpected truncated reflog: %+v, %v", truncated, err)
+	}
+}
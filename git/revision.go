@@ -0,0 +1,68 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UnknownRevisionError is returned when a user-supplied revision is malformed
+// or does not name a commit in the repository.
+type UnknownRevisionError struct {
+	Rev string
+}
+
+func (e *UnknownRevisionError) Error() string {
+	return fmt.Sprintf("unknown revision %q", e.Rev)
+}
+
+// Is makes UnknownRevisionError match ErrBadRevision.
+func (e *UnknownRevisionError) Is(target error) bool {
+	return target == ErrBadRevision
+}
+
+// ResolveRevision resolves a user-supplied revision to a full commit hash.
+// Every revision taken from a request must pass through here before it is
+// placed on a git command line, so values such as "--output=/tmp/x" can never
+// be interpreted as options.
+//
+// Revisions are looked up through the repository's cat-file process instead
+// of "rev-parse --verify --end-of-options", which would start a process per
+// lookup. cat-file reads them from stdin, where they cannot be taken for
+// options either, and answers "missing" for anything that is not a commit,
+// just like a failing rev-parse --verify. Full hashes, which always resolve
+// to the same commit, are remembered.
+func ResolveRevision(ctx context.Context, repoPath, rev string) (string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") || strings.ContainsAny(rev, "\x00\n\r") {
+		return "", &UnknownRevisionError{Rev: rev}
+	}
+
+	resolve := func() (string, error) {
+		info, err := statObject(ctx, repoPath, rev+"^{commit}")
+		if errors.Is(err, ErrNotFound) {
+			return "", &UnknownRevisionError{Rev: rev}
+		}
+		if err != nil {
+			return "", err
+		}
+		return info.Hash, nil
+	}
+	if !isFullHash(rev) {
+		return resolve()
+	}
+	return cached("commit\x00"+repoPath+"\x00"+rev, resolve, stringSize)
+}
+
+// isFullHash reports whether rev is a full SHA-1 or SHA-256 object name.
+func isFullHash(rev string) bool {
+	if len(rev) != 40 && len(rev) != 64 {
+		return false
+	}
+	for _, c := range rev {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveRevisionRejectsOptionsAndUnknownRevisions(t *testing.T) {
+	repoPath, _, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	resolved, err := ResolveRevision(ctx, repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("ResolveRevision(HEAD) returned error: %v", err)
+	}
+	if resolved != hashMove {
+		t.Fatalf("ResolveRevision(HEAD) mismatch: got %q want %q", resolved, hashMove)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "x")
+	for _, rev := range []string{"--output=" + outputPath, "-p", "does-not-exist", "HEAD\nHEAD"} {
+		_, err := ResolveRevision(ctx, repoPath, rev)
+		var unknownRev *UnknownRevisionError
+		if !errors.As(err, &unknownRev) {
+			t.Fatalf("ResolveRevision(%q) expected UnknownRevisionError, got %v", rev, err)
+		}
+	}
+
+	if _, err := GetCommitDiff(ctx, repoPath, "--output="+outputPath); err == nil {
+		t.Fatalf("expected GetCommitDiff to reject option-like hash")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no file to be written at %s, stat returned %v", outputPath, err)
+	}
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StashEntry is one entry of git stash list.
+type StashEntry struct {
+	// Name is the stash reference, e.g. "stash@{0}". It changes as stashes
+	// are added and dropped; Hash does not.
+	Name    string
+	Hash    string
+	Message string
+	// Branch is the branch the stash was made on, "(no branch)" if HEAD was
+	// detached.
+	Branch string
+	// Base is the commit the stash was made on.
+	Base string
+	Time time.Time
+	// HasUntracked is set when the stash also saved untracked files.
+	HasUntracked bool
+}
+
+// Stash diff modes for diffCommands. A stash commit's first parent is the
+// commit it was made on, and its optional third parent a root commit holding
+// the untracked files.
+const (
+	diffModeStash          = "stash"
+	diffModeStashUntracked = "stash-untracked"
+)
+
+// GetStashes returns the stash entries, newest first.
+func GetStashes(ctx context.Context, repoPath string) ([]StashEntry, error) {
+	ref, err := Command(ctx, repoPath, "for-each-ref", "--format=%(refname)", "refs/stash")
+	if err != nil || ref == "" {
+		return nil, err
+	}
+	out, err := Command(ctx, repoPath, "log", "-g", "--format=%H|%P|%ct|%gs", "refs/stash", "--")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+
+	var stashes []StashEntry
+	for i, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "|", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		parents := strings.Fields(parts[1])
+		entry := StashEntry{
+			Name:         fmt.Sprintf("stash@{%d}", i),
+			Hash:         parts[0],
+			Message:      parts[3],
+			Branch:       stashBranch(parts[3]),
+			HasUntracked: len(parents) > 2,
+		}
+		if len(parents) > 0 {
+			entry.Base = parents[0]
+		}
+		if seconds, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+			entry.Time = time.Unix(seconds, 0)
+		}
+		stashes = append(stashes, entry)
+	}
+	return stashes, nil
+}
+
+// stashBranch extracts the branch from messages like "WIP on main: ..." or
+// "On main: ...".
+func stashBranch(message string) string {
+	rest, ok := strings.CutPrefix(message, "WIP on ")
+	if !ok {
+		rest, ok = strings.CutPrefix(message, "On ")
+	}
+	if !ok {
+		return ""
+	}
+	branch, _, _ := strings.Cut(rest, ":")
+	return branch
+}
+
+// GetStashDiff returns the changes saved in a stash relative to the commit it
+// was made on, followed by the untracked files it saved, if any.
+func GetStashDiff(ctx context.Context, repoPath, hash string) (string, error) {
+	mode, err := stashDiffMode(ctx, repoPath, hash)
+	if err != nil {
+		return "", err
+	}
+	return getDiff(ctx, repoPath, hash, mode, "")
+}
+
+// WriteStashDiff streams the diff of GetStashDiff to w without output limits.
+func WriteStashDiff(ctx context.Context, repoPath string, w io.Writer, hash string) error {
+	mode, err := stashDiffMode(ctx, repoPath, hash)
+	if err != nil {
+		return err
+	}
+	return WriteCommitDiff(ctx, repoPath, w, hash, mode, "")
+}
+
+func stashDiffMode(ctx context.Context, repoPath, hash string) (string, error) {
+	parents, err := GetCommitParents(ctx, repoPath, hash)
+	if err != nil {
+		return "", err
+	}
+	if len(parents) < 2 {
+		return "", fmt.Errorf("%w: %s is not a stash", ErrNotFound, hash)
+	}
+	if len(parents) > 2 {
+		return diffModeStashUntracked, nil
+	}
+	return diffModeStash, nil
+}
@@ -0,0 +1,57 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetStashesAndStashDiffIncludeUntrackedFiles(t *testing.T) {
+	repoPath, _, hashMove, _, newPath := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	if stashes, err := GetStashes(ctx, repoPath); err != nil || len(stashes) != 0 {
+		t.Fatalf("expected no stashes, got %v, %v", stashes, err)
+	}
+
+	writeFile(t, filepath.Join(repoPath, newPath), "first stash\n")
+	runGit(t, repoPath, "stash", "push", "-q")
+	writeFile(t, filepath.Join(repoPath, newPath), "second stash\n")
+	writeFile(t, filepath.Join(repoPath, "scratch.txt"), "untracked notes\n")
+	runGit(t, repoPath, "stash", "push", "-q", "--include-untracked", "-m", "with notes")
+
+	stashes, err := GetStashes(ctx, repoPath)
+	if err != nil {
+		t.Fatalf("GetStashes returned error: %v", err)
+	}
+	if len(stashes) != 2 {
+		t.Fatalf("expected 2 stashes, got %+v", stashes)
+	}
+	branch := runGit(t, repoPath, "branch", "--show-current")
+	latest := stashes[0]
+	if latest.Name != "stash@{0}" || latest.Message != "On "+branch+": with notes" || latest.Branch != branch || latest.Base != hashMove || !latest.HasUntracked {
+		t.Fatalf("unexpected latest stash: %+v", latest)
+	}
+	if stashes[1].Name != "stash@{1}" || !strings.HasPrefix(stashes[1].Message, "WIP on "+branch) || stashes[1].HasUntracked {
+		t.Fatalf("unexpected older stash: %+v", stashes[1])
+	}
+
+	diff, err := GetStashDiff(ctx, repoPath, latest.Hash)
+	if err != nil {
+		t.Fatalf("GetStashDiff returned error: %v", err)
+	}
+	for _, want := range []string{"+second stash", "+untracked notes", "b/scratch.txt"} {
+		if !strings.Contains(diff, want) {
+			t.Fatalf("expected stash diff to contain %q, got %q", want, diff)
+		}
+	}
+	var raw bytes.Buffer
+	if err := WriteStashDiff(ctx, repoPath, &raw, stashes[1].Hash); err != nil {
+		t.Fatalf("WriteStashDiff returned error: %v", err)
+	}
+	if !strings.Contains(raw.String(), "+first stash") || strings.Contains(raw.String(), "scratch.txt") {
+		t.Fatalf("unexpected raw stash diff: %q", raw.String())
+	}
+}
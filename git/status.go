@@ -0,0 +1,176 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Status is the state of a work tree as reported by git status.
+type Status struct {
+	// Branch is the checked out branch, empty when HEAD is detached.
+	Branch string
+	// Head is the commit HEAD points to, empty before the first commit.
+	Head     string
+	Upstream string
+	Ahead    int
+	Behind   int
+	Entries  []StatusEntry
+}
+
+// StatusEntry is one changed, untracked or conflicted path. Index and
+// WorkTree hold git's status letters, e.g. "M" for modified, or "." when that
+// side is unchanged.
+type StatusEntry struct {
+	Path string
+	// OrigPath is the previous path of a renamed or copied file.
+	OrigPath   string
+	Index      string
+	WorkTree   string
+	Untracked  bool
+	Conflicted bool
+}
+
+// Staged reports whether the entry has changes in the index.
+func (e StatusEntry) Staged() bool {
+	return !e.Untracked && !e.Conflicted && e.Index != "."
+}
+
+// Unstaged reports whether the work tree differs from the index.
+func (e StatusEntry) Unstaged() bool {
+	return !e.Untracked && !e.Conflicted && e.WorkTree != "."
+}
+
+// IsDir reports whether the entry is an untracked directory. Its path ends
+// with a slash.
+func (e StatusEntry) IsDir() bool {
+	return e.Untracked && strings.HasSuffix(e.Path, "/")
+}
+
+// GetStatus returns the branch and the changed, staged, untracked and
+// conflicted files of the work tree. Git is told not to take optional locks,
+// so looking at the status never modifies the repository.
+func GetStatus(ctx context.Context, repoPath string) (Status, error) {
+	var status Status
+	if bare, err := IsBareRepository(ctx, repoPath); err != nil {
+		return status, err
+	} else if bare {
+		return status, ErrNoWorkTree
+	}
+
+	// Untracked directories are listed once rather than file by file, so a
+	// build output or dependency directory does not overflow MaxLogBytes.
+	out, err := Command(ctx, repoPath, "--no-optional-locks", "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=normal")
+	if err != nil {
+		return status, err
+	}
+	return parseStatus(out), nil
+}
+
+func parseStatus(out string) Status {
+	var status Status
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+		switch record[0] {
+		case '#':
+			parseStatusHeader(&status, record)
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			if fields := strings.SplitN(record, " ", 9); len(fields) == 9 {
+				status.Entries = append(status.Entries, newStatusEntry(fields[1], fields[8]))
+			}
+		case '2':
+			// 2 XY sub mH mI mW hH hI Xscore path, followed by the original path
+			if fields := strings.SplitN(record, " ", 10); len(fields) == 10 {
+				entry := newStatusEntry(fields[1], fields[9])
+				if i+1 < len(records) {
+					i++
+					entry.OrigPath = records[i]
+				}
+				status.Entries = append(status.Entries, entry)
+			}
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if fields := strings.SplitN(record, " ", 11); len(fields) == 11 {
+				entry := newStatusEntry(fields[1], fields[10])
+				entry.Conflicted = true
+				status.Entries = append(status.Entries, entry)
+			}
+		case '?':
+			status.Entries = append(status.Entries, StatusEntry{Path: strings.TrimPrefix(record, "? "), Index: "?", WorkTree: "?", Untracked: true})
+		}
+	}
+	return status
+}
+
+func newStatusEntry(xy, path string) StatusEntry {
+	entry := StatusEntry{Path: path, Index: ".", WorkTree: "."}
+	if len(xy) == 2 {
+		entry.Index, entry.WorkTree = xy[:1], xy[1:]
+	}
+	return entry
+}
+
+func parseStatusHeader(status *Status, record string) {
+	fields := strings.Fields(record)
+	if len(fields) < 3 {
+		return
+	}
+	switch fields[1] {
+	case "branch.oid":
+		if fields[2] != "(initial)" {
+			status.Head = fields[2]
+		}
+	case "branch.head":
+		if fields[2] != "(detached)" {
+			status.Branch = fields[2]
+		}
+	case "branch.upstream":
+		status.Upstream = fields[2]
+	case "branch.ab":
+		if len(fields) == 4 {
+			status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+			status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+		}
+	}
+}
+
+// GetWorkTreeDiff returns the uncommitted diff of paths: the staged changes
+// against HEAD when staged is set, otherwise the unstaged changes against the
+// index. Pass both paths of a rename to see it as one. Callers must only pass
+// paths reported by GetStatus.
+func GetWorkTreeDiff(ctx context.Context, repoPath string, staged bool, paths ...string) (string, error) {
+	// Paths are file names, not patterns: a file named "*.go" must not select
+	// every Go file.
+	args := []string{"--no-optional-locks", "--literal-pathspecs", "diff"}
+	if staged {
+		args = append(args, "--cached", "-M")
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	return commandLimited(ctx, repoPath, currentLimits().MaxDiffBytes, args...)
+}
+
+// GetUntrackedDiff returns the content of an untracked file as a diff adding
+// it. Callers must only pass paths reported by GetStatus as untracked files,
+// not directories.
+func GetUntrackedDiff(ctx context.Context, repoPath, path string) (string, error) {
+	limit := currentLimits().MaxDiffBytes
+	stdout := &limitedBuffer{limit: limit}
+	err := run(ctx, repoPath, stdout, "--no-optional-locks", "diff", "--no-index", "--", "/dev/null", path)
+	if stdout.exceeded {
+		return "", &TooLargeError{Limit: limit}
+	}
+	// diff --no-index exits with 1 when the files differ, which they always do.
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
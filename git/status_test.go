@@ -0,0 +1,134 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetStatusListsStagedUnstagedUntrackedAndRenamed(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+	writeFile(t, filepath.Join(repoPath, "README"), "read me\nplease\n")
+	runGit(t, repoPath, "add", "README")
+	runGit(t, repoPath, "commit", "-q", "-m", "add README")
+
+	writeFile(t, filepath.Join(repoPath, newPath), "staged\n")
+	runGit(t, repoPath, "add", newPath)
+	writeFile(t, filepath.Join(repoPath, newPath), "staged\nand unstaged\n")
+	writeFile(t, filepath.Join(repoPath, "new file.txt"), "untracked\n")
+	writeFile(t, filepath.Join(repoPath, "notes dir", "a.txt"), "a\n")
+	writeFile(t, filepath.Join(repoPath, "notes dir", "b.txt"), "b\n")
+	runGit(t, repoPath, "mv", "README", "README.md")
+
+	status, err := GetStatus(ctx, repoPath)
+	if err != nil {
+		t.Fatalf("GetStatus returned error: %v", err)
+	}
+	if status.Branch == "" || len(status.Head) != 40 {
+		t.Fatalf("unexpected branch info: %+v", status)
+	}
+
+	byPath := make(map[string]StatusEntry)
+	for _, entry := range status.Entries {
+		byPath[entry.Path] = entry
+	}
+	moved, ok := byPath["README.md"]
+	if !ok || moved.OrigPath != "README" || moved.Index != "R" || !moved.Staged() || moved.Unstaged() {
+		t.Fatalf("unexpected entry for renamed file: %+v (all: %+v)", moved, status.Entries)
+	}
+	modified, ok := byPath[newPath]
+	if !ok || modified.Index != "M" || modified.WorkTree != "M" || !modified.Staged() || !modified.Unstaged() {
+		t.Fatalf("unexpected entry for modified file: %+v", modified)
+	}
+	untracked, ok := byPath["new file.txt"]
+	if !ok || !untracked.Untracked || untracked.Staged() || untracked.IsDir() {
+		t.Fatalf("unexpected entry for untracked file: %+v", untracked)
+	}
+	dir, ok := byPath["notes dir/"]
+	if !ok || !dir.IsDir() {
+		t.Fatalf("expected the untracked directory as one entry, got %+v", status.Entries)
+	}
+
+	staged, err := GetWorkTreeDiff(ctx, repoPath, true, newPath)
+	if err != nil || !strings.Contains(staged, "+staged") || strings.Contains(staged, "and unstaged") {
+		t.Fatalf("unexpected staged diff: %q, %v", staged, err)
+	}
+	unstaged, err := GetWorkTreeDiff(ctx, repoPath, false, newPath)
+	if err != nil || !strings.Contains(unstaged, "+and unstaged") {
+		t.Fatalf("unexpected unstaged diff: %q, %v", unstaged, err)
+	}
+	renamed, err := GetWorkTreeDiff(ctx, repoPath, true, "README.md", "README")
+	if err != nil || !strings.Contains(renamed, "rename from README") {
+		t.Fatalf("unexpected diff for renamed file: %q, %v", renamed, err)
+	}
+	added, err := GetUntrackedDiff(ctx, repoPath, "new file.txt")
+	if err != nil || !strings.Contains(added, "+untracked") {
+		t.Fatalf("unexpected untracked diff: %q, %v", added, err)
+	}
+}
+
+func TestGetWorkTreeDiffTakesPathsLiterally(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+	writeFile(t, filepath.Join(repoPath, "b*"), "star\n")
+	writeFile(t, filepath.Join(repoPath, "build.txt"), "build\n")
+	runGit(t, repoPath, "add", "b*", "build.txt")
+	runGit(t, repoPath, "commit", "-q", "-m", "add files")
+	writeFile(t, filepath.Join(repoPath, "b*"), "star changed\n")
+	writeFile(t, filepath.Join(repoPath, "build.txt"), "build changed\n")
+
+	diff, err := GetWorkTreeDiff(ctx, repoPath, false, "b*")
+	if err != nil || !strings.Contains(diff, "+star changed") {
+		t.Fatalf("unexpected diff: %q, %v", diff, err)
+	}
+	if strings.Contains(diff, "build.txt") {
+		t.Fatalf("expected the path not to be taken as a glob, got %q", diff)
+	}
+}
+
+func TestGetStatusReportsConflicts(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFile(t)
+	runGit(t, repoPath, "checkout", "-q", "-b", "other", "HEAD~1")
+	runGit(t, repoPath, "checkout", "-q", "-")
+	writeFile(t, filepath.Join(repoPath, "conflict.txt"), "ours\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-q", "-m", "ours")
+	runGit(t, repoPath, "checkout", "-q", "other")
+	writeFile(t, filepath.Join(repoPath, "conflict.txt"), "theirs\n")
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-q", "-m", "theirs")
+	runGit(t, repoPath, "checkout", "-q", "-")
+	merge := exec.Command("git", "merge", "other")
+	merge.Dir = repoPath
+	if err := merge.Run(); err == nil {
+		t.Fatalf("expected merge to stop with a conflict")
+	}
+
+	status, err := GetStatus(context.Background(), repoPath)
+	if err != nil {
+		t.Fatalf("GetStatus returned error: %v", err)
+	}
+	for _, entry := range status.Entries {
+		if entry.Path == "conflict.txt" {
+			if !entry.Conflicted {
+				t.Fatalf("expected conflict, got %+v", entry)
+			}
+			return
+		}
+	}
+	t.Fatalf("conflicted file missing from status: %+v", status.Entries)
+}
+
+func TestGetStatusRejectsBareRepository(t *testing.T) {
+	source, _, _, _, _ := setupRepoWithRenamedFile(t)
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	runGit(t, source, "clone", "-q", "--bare", source, bare)
+
+	if _, err := GetStatus(context.Background(), bare); !errors.Is(err, ErrNoWorkTree) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNoWorkTree, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Submodule is a submodule declared in .gitmodules.
+type Submodule struct {
+	Name string
+	Path string
+	URL  string
+}
+
+// GetSubmodules returns the submodules declared in .gitmodules at rev, keyed
+// by path. It returns an empty map when the revision has no .gitmodules.
+func GetSubmodules(ctx context.Context, repoPath, rev string) (map[string]Submodule, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	commit, err := ResolveRevision(ctx, repoPath, rev)
+	if err != nil {
+		return nil, err
+	}
+	info, err := statObject(ctx, repoPath, commit+":.gitmodules")
+	if errors.Is(err, ErrNotFound) {
+		return map[string]Submodule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cached("gitmodules\x00"+info.Hash, func() (map[string]Submodule, error) {
+		out, err := Command(ctx, repoPath, "config", "--blob", info.Hash, "--null", "--list")
+		if err != nil {
+			return nil, err
+		}
+		return parseSubmoduleConfig(out), nil
+	}, submodulesSize)
+}
+
+// parseSubmoduleConfig reads the "key\nvalue" records printed by
+// git config --null --list. Submodule names may contain dots, so the key is
+// split at its last dot.
+func parseSubmoduleConfig(out string) map[string]Submodule {
+	byName := make(map[string]*Submodule)
+	var names []string
+	for _, record := range strings.Split(out, "\x00") {
+		key, value, _ := strings.Cut(record, "\n")
+		rest, ok := strings.CutPrefix(key, "submodule.")
+		if !ok {
+			continue
+		}
+		dot := strings.LastIndexByte(rest, '.')
+		if dot <= 0 {
+			continue
+		}
+		name, variable := rest[:dot], rest[dot+1:]
+		sub, ok := byName[name]
+		if !ok {
+			sub = &Submodule{Name: name}
+			byName[name] = sub
+			names = append(names, name)
+		}
+		switch variable {
+		case "path":
+			sub.Path = strings.Trim(value, "/")
+		case "url":
+			sub.URL = value
+		}
+	}
+
+	submodules := make(map[string]Submodule, len(names))
+	for _, name := range names {
+		if sub := byName[name]; sub.Path != "" {
+			submodules[sub.Path] = *sub
+		}
+	}
+	return submodules
+}
+
+func submodulesSize(submodules map[string]Submodule) int64 {
+	size := int64(0)
+	for _, sub := range submodules {
+		size += int64(2*len(sub.Path)+len(sub.Name)+len(sub.URL)) + 80
+	}
+	return size
+}
@@ -0,0 +1,45 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetSubmodulesReadsGitmodulesAtRevision(t *testing.T) {
+	repoPath, hashSwitch, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	writeFile(t, filepath.Join(repoPath, ".gitmodules"), `[submodule "libs/dep.v2"]
+	path = libs/dep
+	url = https://example.com/dep.git
+[submodule "no-path"]
+	url = https://example.com/other.git
+`)
+	runGit(t, repoPath, "add", ".gitmodules")
+	runGit(t, repoPath, "update-index", "--add", "--cacheinfo", "160000,"+hashSwitch+",libs/dep")
+	runGit(t, repoPath, "commit", "-q", "-m", "add dep")
+
+	submodules, err := GetSubmodules(ctx, repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("GetSubmodules returned error: %v", err)
+	}
+	want := map[string]Submodule{"libs/dep": {Name: "libs/dep.v2", Path: "libs/dep", URL: "https://example.com/dep.git"}}
+	if !reflect.DeepEqual(submodules, want) {
+		t.Fatalf("unexpected submodules: got %+v want %+v", submodules, want)
+	}
+
+	entries, err := ListTree(ctx, repoPath, "HEAD", "libs")
+	if err != nil {
+		t.Fatalf("ListTree returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Type != "commit" || entries[0].Hash != hashSwitch {
+		t.Fatalf("unexpected submodule tree entry: %+v", entries)
+	}
+
+	submodules, err = GetSubmodules(ctx, repoPath, hashMove)
+	if err != nil || len(submodules) != 0 {
+		t.Fatalf("expected no submodules before .gitmodules existed, got %+v, %v", submodules, err)
+	}
+}
@@ -0,0 +1,47 @@
+package git
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepoSummary describes the activity of a repository for overview pages.
+type RepoSummary struct {
+	DefaultBranch string
+	Branches      int
+	// LastCommit is the most recent commit on any branch, nil if there is none.
+	LastCommit *LastCommit
+}
+
+// GetRepoSummary returns the default branch, the number of branches and the
+// most recently committed branch tip of a repository.
+func GetRepoSummary(ctx context.Context, repoPath string) (RepoSummary, error) {
+	var summary RepoSummary
+	out, err := Command(ctx, repoPath, "for-each-ref", "--sort=-committerdate",
+		"--format=%(objectname)|%(committerdate:unix)|%(contents:subject)", "refs/heads")
+	if err != nil {
+		return summary, err
+	}
+	if out == "" {
+		return summary, nil
+	}
+
+	lines := strings.Split(out, "\n")
+	summary.Branches = len(lines)
+	parts := strings.SplitN(lines[0], "|", 3)
+	if len(parts) == 3 {
+		commit := &LastCommit{Hash: parts[0], Subject: parts[2]}
+		if seconds, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			commit.Time = time.Unix(seconds, 0)
+		}
+		summary.LastCommit = commit
+	}
+
+	summary.DefaultBranch, err = GetDefaultBranch(ctx, repoPath)
+	if err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,33 @@
+package git
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetRepoSummaryReportsLatestBranchTip(t *testing.T) {
+	repoPath, _, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	branch := runGit(t, repoPath, "branch", "--show-current")
+	runGit(t, repoPath, "branch", "older", "HEAD~1")
+
+	summary, err := GetRepoSummary(context.Background(), repoPath)
+	if err != nil {
+		t.Fatalf("GetRepoSummary returned error: %v", err)
+	}
+	if summary.DefaultBranch != branch || summary.Branches != 2 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.LastCommit == nil || summary.LastCommit.Hash != hashMove || summary.LastCommit.Subject != "moved Android app to a subfolder" {
+		t.Fatalf("unexpected last commit: %+v", summary.LastCommit)
+	}
+	if summary.LastCommit.Time.IsZero() {
+		t.Fatalf("expected last commit time to be set")
+	}
+
+	empty := t.TempDir()
+	runGit(t, empty, "init")
+	summary, err = GetRepoSummary(context.Background(), empty)
+	if err != nil || summary.Branches != 0 || summary.LastCommit != nil {
+		t.Fatalf("unexpected summary for empty repo: %+v, %v", summary, err)
+	}
+}
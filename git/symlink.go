@@ -0,0 +1,146 @@
+package git
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// maxSymlinkHops is how many symlinks are followed while resolving a target
+// before it is considered a loop, the same limit the Linux kernel uses.
+const maxSymlinkHops = 40
+
+// Symlink describes a symbolic link stored in a tree.
+type Symlink struct {
+	// Target is the link target as stored in the repository.
+	Target string
+	// Path and Type locate what the target resolves to at the same revision,
+	// following further symlinks. Path is empty for the repository root.
+	// Both are only set when the link is neither Escaping nor Broken.
+	Path string
+	Type string
+	// Escaping is set when the target is absolute or leaves the repository.
+	Escaping bool
+	// Broken is set when the target does not exist at the revision, or the
+	// links form a loop.
+	Broken bool
+}
+
+// IsSymlink reports whether the entry is a symbolic link.
+func (e TreeEntry) IsSymlink() bool {
+	return e.Mode == "120000"
+}
+
+// ResolveSymlink resolves the symlink entry within the repository at commit,
+// a resolved commit hash as returned by StatFile. It returns nil if entry is
+// not a symlink.
+func ResolveSymlink(ctx context.Context, repoPath, commit string, entry TreeEntry) (*Symlink, error) {
+	if !entry.IsSymlink() {
+		return nil, nil
+	}
+	dir, _ := splitPath(entry.Path)
+	target, err := readSymlinkTarget(ctx, repoPath, entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &Symlink{Target: target}
+	if path.IsAbs(target) {
+		link.Escaping = true
+		return link, nil
+	}
+
+	// Walk the target component by component from the link's directory,
+	// splicing in the targets of symlinks met on the way.
+	var current []string
+	if dir != "" {
+		current = strings.Split(dir, "/")
+	}
+	remaining := strings.Split(target, "/")
+	resolvedType := "tree"
+	for hops := 1; len(remaining) > 0; {
+		name := remaining[0]
+		remaining = remaining[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if len(current) == 0 {
+				link.Escaping = true
+				return link, nil
+			}
+			current = current[:len(current)-1]
+			resolvedType = "tree"
+			continue
+		}
+		if resolvedType != "tree" {
+			link.Broken = true
+			return link, nil
+		}
+
+		next, err := lookupEntry(ctx, repoPath, commit, path.Join(append(current, name)...))
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			link.Broken = true
+			return link, nil
+		}
+		if next.IsSymlink() {
+			if hops++; hops > maxSymlinkHops {
+				link.Broken = true
+				return link, nil
+			}
+			nested, err := readSymlinkTarget(ctx, repoPath, next.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if path.IsAbs(nested) {
+				link.Escaping = true
+				return link, nil
+			}
+			remaining = append(strings.Split(nested, "/"), remaining...)
+			continue
+		}
+		current = append(current, name)
+		resolvedType = next.Type
+	}
+
+	link.Path = strings.Join(current, "/")
+	link.Type = resolvedType
+	return link, nil
+}
+
+// lookupEntry returns the tree entry at filePath in commit, or nil if there
+// is none. Trees are read through the cache, so following a path costs no
+// process.
+func lookupEntry(ctx context.Context, repoPath, commit, filePath string) (*TreeEntry, error) {
+	dir, name := splitPath(filePath)
+	entries, err := listTree(ctx, repoPath, commit, dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Name == name {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func readSymlinkTarget(ctx context.Context, repoPath, hash string) (string, error) {
+	_, content, err := readObject(ctx, repoPath, hash)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// splitPath splits a repository-relative path into its directory, empty at
+// the root, and last element.
+func splitPath(filePath string) (dir, name string) {
+	if i := strings.LastIndexByte(filePath, '/'); i >= 0 {
+		return filePath[:i], filePath[i+1:]
+	}
+	return "", filePath
+}
@@ -0,0 +1,80 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSymlinkWithinRepository(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	links := map[string]string{
+		"docs/main.kt":     "../" + newPath,
+		"docs/android":     "../Android",
+		"docs/via-dir":     "android/androidApp/src",
+		"docs/root":        "..",
+		"docs/escaping":    "../../outside",
+		"docs/absolute":    "/etc/passwd",
+		"docs/broken":      "missing.txt",
+		"docs/loop-a":      "loop-b",
+		"docs/loop-b":      "loop-a",
+		"docs/file-as-dir": "main.kt/child",
+	}
+	if err := os.MkdirAll(filepath.Join(repoPath, "docs"), 0o755); err != nil {
+		t.Fatalf("mkdir docs: %v", err)
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(repoPath, name)); err != nil {
+			t.Fatalf("symlink %s: %v", name, err)
+		}
+	}
+	runGit(t, repoPath, "add", "docs")
+	runGit(t, repoPath, "commit", "-q", "-m", "add links")
+
+	cases := []struct {
+		path string
+		want Symlink
+	}{
+		{"docs/main.kt", Symlink{Target: "../" + newPath, Path: newPath, Type: "blob"}},
+		{"docs/android", Symlink{Target: "../Android", Path: "Android", Type: "tree"}},
+		{"docs/via-dir", Symlink{Target: "android/androidApp/src", Path: "Android/androidApp/src", Type: "tree"}},
+		{"docs/root", Symlink{Target: "..", Path: "", Type: "tree"}},
+		{"docs/escaping", Symlink{Target: "../../outside", Escaping: true}},
+		{"docs/absolute", Symlink{Target: "/etc/passwd", Escaping: true}},
+		{"docs/broken", Symlink{Target: "missing.txt", Broken: true}},
+		{"docs/loop-a", Symlink{Target: "loop-b", Broken: true}},
+		{"docs/file-as-dir", Symlink{Target: "main.kt/child", Broken: true}},
+	}
+	for _, tc := range cases {
+		file, err := StatFile(ctx, repoPath, "HEAD", tc.path)
+		if err != nil {
+			t.Fatalf("%s: StatFile returned error: %v", tc.path, err)
+		}
+		link, err := ResolveSymlink(ctx, repoPath, file.Commit, file.TreeEntry)
+		if err != nil {
+			t.Fatalf("%s: ResolveSymlink returned error: %v", tc.path, err)
+		}
+		if link == nil || *link != tc.want {
+			t.Fatalf("%s: got %+v want %+v", tc.path, link, tc.want)
+		}
+	}
+
+	file, err := StatFile(ctx, repoPath, "HEAD", newPath)
+	if err != nil || file.IsSymlink() || file.Size == 0 {
+		t.Fatalf("unexpected regular file info %+v, %v", file, err)
+	}
+	if link, err := ResolveSymlink(ctx, repoPath, file.Commit, file.TreeEntry); err != nil || link != nil {
+		t.Fatalf("expected regular file not to be a symlink, got %+v, %v", link, err)
+	}
+	if _, err := StatFile(ctx, repoPath, "HEAD", "docs"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a directory, got %v", err)
+	}
+	entries, err := ListTree(ctx, repoPath, "HEAD", "docs")
+	if err != nil || len(entries) != len(links) || !entries[0].IsSymlink() {
+		t.Fatalf("expected symlink tree entries, got %+v, %v", entries, err)
+	}
+}
@@ -0,0 +1,71 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is one working tree attached to a repository, as listed by
+// git worktree list.
+type Worktree struct {
+	Path string
+	// Head is the checked-out commit, empty for a bare repository.
+	Head string
+	// Branch is the checked-out branch without "refs/heads/", empty when
+	// HEAD is detached.
+	Branch string
+	// Main is set for the repository's own working tree, or the bare
+	// repository itself; the others are linked worktrees.
+	Main     bool
+	Bare     bool
+	Detached bool
+	// Locked is set when the worktree is protected from pruning, with the
+	// reason given to git worktree lock, if any.
+	Locked     bool
+	LockReason string
+	// Prunable is set when the worktree's directory is gone and git
+	// worktree prune would remove it.
+	Prunable    bool
+	PruneReason string
+}
+
+// GetWorktrees returns the main working tree followed by the linked
+// worktrees of the repository at repoPath. repoPath may itself be any of
+// them.
+func GetWorktrees(ctx context.Context, repoPath string) ([]Worktree, error) {
+	out, err := Command(ctx, repoPath, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []Worktree
+	for _, record := range strings.Split(out, "\n\n") {
+		var wt Worktree
+		for _, line := range strings.Split(record, "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "worktree":
+				wt.Path = filepath.Clean(value)
+			case "HEAD":
+				wt.Head = value
+			case "branch":
+				wt.Branch = strings.TrimPrefix(value, "refs/heads/")
+			case "bare":
+				wt.Bare = true
+			case "detached":
+				wt.Detached = true
+			case "locked":
+				wt.Locked, wt.LockReason = true, value
+			case "prunable":
+				wt.Prunable, wt.PruneReason = true, value
+			}
+		}
+		if wt.Path == "" {
+			continue
+		}
+		wt.Main = len(worktrees) == 0
+		worktrees = append(worktrees, wt)
+	}
+	return worktrees, nil
+}
@@ -0,0 +1,67 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetWorktreesListsLinkedWorktrees(t *testing.T) {
+	repoPath, hashSwitch, hashMove, _, _ := setupRepoWithRenamedFile(t)
+	ctx := context.Background()
+
+	base := t.TempDir()
+	feature := filepath.Join(base, "feature")
+	detached := filepath.Join(base, "detached")
+	gone := filepath.Join(base, "gone")
+	runGit(t, repoPath, "worktree", "add", "-q", "-b", "feature", feature)
+	runGit(t, repoPath, "worktree", "lock", "--reason", "on a USB disk", feature)
+	runGit(t, repoPath, "worktree", "add", "-q", "--detach", detached, hashSwitch)
+	runGit(t, repoPath, "worktree", "add", "-q", "--detach", gone)
+	if err := os.RemoveAll(gone); err != nil {
+		t.Fatalf("remove worktree: %v", err)
+	}
+
+	// Any worktree lists all of them, starting with the main one.
+	worktrees, err := GetWorktrees(ctx, detached)
+	if err != nil {
+		t.Fatalf("GetWorktrees returned error: %v", err)
+	}
+	if len(worktrees) != 4 {
+		t.Fatalf("expected 4 worktrees, got %+v", worktrees)
+	}
+	byPath := make(map[string]Worktree)
+	for _, wt := range worktrees {
+		byPath[wt.Path] = wt
+	}
+
+	branch := runGit(t, repoPath, "branch", "--show-current")
+	main := worktrees[0]
+	if !main.Main || main.Path != filepath.Clean(repoPath) || main.Branch != branch || main.Head != hashMove {
+		t.Fatalf("unexpected main worktree: %+v", main)
+	}
+	if wt := byPath[feature]; wt.Main || wt.Branch != "feature" || !wt.Locked || wt.LockReason != "on a USB disk" || wt.Prunable {
+		t.Fatalf("unexpected feature worktree: %+v", wt)
+	}
+	if wt := byPath[detached]; !wt.Detached || wt.Branch != "" || wt.Head != hashSwitch || wt.Locked {
+		t.Fatalf("unexpected detached worktree: %+v", wt)
+	}
+	if wt := byPath[gone]; !wt.Prunable || wt.PruneReason == "" {
+		t.Fatalf("unexpected removed worktree: %+v", wt)
+	}
+}
+
+func TestGetWorktreesOfBareRepository(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFile(t)
+	barePath := filepath.Join(t.TempDir(), "mirror.git")
+	runGit(t, repoPath, "clone", "-q", "--bare", repoPath, barePath)
+
+	worktrees, err := GetWorktrees(context.Background(), barePath)
+	if err != nil {
+		t.Fatalf("GetWorktrees returned error: %v", err)
+	}
+	if len(worktrees) != 1 || !worktrees[0].Main || !worktrees[0].Bare || worktrees[0].Head != "" {
+		t.Fatalf("unexpected worktrees of bare repository: %+v", worktrees)
+	}
+}
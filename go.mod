@@ -2,4 +2,10 @@ module github.com/andrebering/gitBrowser
 
 go 1.22.3
 
-require github.com/go-chi/chi/v5 v5.2.5 // indirect
+require (
+	github.com/coreos/go-oidc/v3 v3.11.0
+	github.com/go-chi/chi/v5 v5.2.5
+	github.com/go-jose/go-jose/v4 v4.0.5
+	golang.org/x/crypto v0.33.0
+	golang.org/x/oauth2 v0.26.0
+)
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+// Geometry of the commit graph drawn next to each entry in commits.html.
+const (
+	graphLaneWidth = 16
+	graphRowHeight = 72
+	graphNodeSize  = 4
+)
+
+var graphColors = []string{
+	"#0969da", "#2da44e", "#bf8700", "#cf222e", "#8250df", "#1b7c83", "#bc4c00", "#e85aad",
+}
+
+type commitGraphEntry struct {
+	git.LogEntry
+	Graph graphRowView
+}
+
+type graphRowView struct {
+	Width  int
+	Height int
+	NodeX  int
+	NodeY  int
+	NodeR  int
+	Color  string
+	Paths  []graphPathView
+}
+
+type graphPathView struct {
+	D     string
+	Color string
+}
+
+// newGraphRowView converts a lane-based graph row into SVG coordinates.
+func newGraphRowView(row git.GraphRow) graphRowView {
+	view := graphRowView{
+		Width:  row.Width * graphLaneWidth,
+		Height: graphRowHeight,
+		NodeX:  laneX(row.Column),
+		NodeY:  rowY(1),
+		NodeR:  graphNodeSize,
+		Color:  graphColor(row.Color),
+	}
+	for _, edge := range row.Edges {
+		x1, y1 := laneX(edge.FromLane), rowY(edge.FromY)
+		x2, y2 := laneX(edge.ToLane), rowY(edge.ToY)
+		var d string
+		if x1 == x2 {
+			d = fmt.Sprintf("M%d %d L%d %d", x1, y1, x2, y2)
+		} else {
+			midY := (y1 + y2) / 2
+			d = fmt.Sprintf("M%d %d C%d %d %d %d %d %d", x1, y1, x1, midY, x2, midY, x2, y2)
+		}
+		view.Paths = append(view.Paths, graphPathView{D: d, Color: graphColor(edge.Color)})
+	}
+	return view
+}
+
+func laneX(lane int) int {
+	return lane*graphLaneWidth + graphLaneWidth/2
+}
+
+// rowY maps a graph edge position to a y coordinate. The top and bottom
+// overshoot by a pixel so lines stay connected across row borders.
+func rowY(pos int) int {
+	switch pos {
+	case 0:
+		return -1
+	case 1:
+		return graphRowHeight / 2
+	default:
+		return graphRowHeight + 1
+	}
+}
+
+func graphColor(i int) string {
+	return graphColors[i%len(graphColors)]
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+const (
+	// repoSummaryTTL is how long the activity shown on the index is reused.
+	repoSummaryTTL = time.Minute
+	// repoSummaryWorkers bounds how many repos are summarized at once.
+	repoSummaryWorkers = 8
+)
+
+type repoIndexEntry struct {
+	Name        string
+	Description string
+	// Summary is nil if the repository could not be read.
+	Summary *git.RepoSummary
+}
+
+type repoIndexGroup struct {
+	Name  string
+	Repos []repoIndexEntry
+}
+
+type indexViewData struct {
+	baseViewData
+	Query  string
+	Sort   string
+	Groups []repoIndexGroup
+	Total  int
+}
+
+// summaryCache keeps recent repo summaries by repository path.
+type summaryCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSummary
+}
+
+type cachedSummary struct {
+	summary *git.RepoSummary
+	fetched time.Time
+}
+
+// retain drops the summaries of repository paths not in inUse.
+func (c *summaryCache) retain(inUse map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for repoPath := range c.entries {
+		if !inUse[repoPath] {
+			delete(c.entries, repoPath)
+		}
+	}
+}
+
+// indexHandler lists the repositories the user may read, grouped by their
+// configured group, with their recent activity. The q parameter filters by
+// name, group and description; sort=activity orders by the latest commit.
+func (a *app) indexHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "activity" {
+		sortBy = "name"
+	}
+
+	visible := a.visibleRepos(ctx)
+	a.mu.RLock()
+	var entries []repoIndexEntry
+	var groups []string
+	paths := make(map[string]string)
+	for _, name := range visible {
+		details := a.details[name]
+		if !matchesRepoQuery(query, name, details) {
+			continue
+		}
+		entries = append(entries, repoIndexEntry{Name: name, Description: details.Description})
+		groups = append(groups, details.Group)
+		paths[name] = a.repos[name]
+	}
+	a.mu.RUnlock()
+
+	summaries := a.repoSummaries(ctx, paths)
+	byGroup := make(map[string][]repoIndexEntry)
+	for i, entry := range entries {
+		entry.Summary = summaries[entry.Name]
+		byGroup[groups[i]] = append(byGroup[groups[i]], entry)
+	}
+
+	data := indexViewData{
+		baseViewData: baseViewData{Repos: visible, User: userFromContext(ctx)},
+		Query:        query,
+		Sort:         sortBy,
+		Groups:       groupRepos(byGroup, sortBy),
+	}
+	for _, group := range data.Groups {
+		data.Total += len(group.Repos)
+	}
+	render(w, r, "index.html", data)
+}
+
+// repoSummaries returns the summary of each repo in paths, keyed by name.
+// Summaries older than repoSummaryTTL are gathered again, several at a time.
+func (a *app) repoSummaries(ctx context.Context, paths map[string]string) map[string]*git.RepoSummary {
+	result := make(map[string]*git.RepoSummary, len(paths))
+	var missing []string
+
+	a.summaries.mu.Lock()
+	if a.summaries.entries == nil {
+		a.summaries.entries = make(map[string]cachedSummary)
+	}
+	for name, repoPath := range paths {
+		cached, ok := a.summaries.entries[repoPath]
+		if ok && time.Since(cached.fetched) < repoSummaryTTL {
+			result[name] = cached.summary
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	a.summaries.mu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, repoSummaryWorkers)
+	for _, name := range missing {
+		wg.Add(1)
+		go func(name, repoPath string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			var summary *git.RepoSummary
+			s, err := git.GetRepoSummary(ctx, repoPath)
+			if err == nil {
+				summary = &s
+			} else if ctx.Err() != nil {
+				return
+			} else {
+				log.Printf("summarize repo %q: %v", name, err)
+			}
+
+			mu.Lock()
+			result[name] = summary
+			mu.Unlock()
+			a.summaries.mu.Lock()
+			a.summaries.entries[repoPath] = cachedSummary{summary: summary, fetched: time.Now()}
+			a.summaries.mu.Unlock()
+		}(name, paths[name])
+	}
+	wg.Wait()
+	return result
+}
+
+func matchesRepoQuery(query, name string, details repoDetails) bool {
+	if query == "" {
+		return true
+	}
+	query = strings.ToLower(query)
+	for _, field := range []string{name, details.Group, details.Description} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupRepos orders groups by name, with repos without a group last. Within a
+// group, repos are ordered by name or, for sortBy "activity", by their latest
+// commit.
+func groupRepos(byGroup map[string][]repoIndexEntry, sortBy string) []repoIndexGroup {
+	groups := make([]repoIndexGroup, 0, len(byGroup))
+	for name, repos := range byGroup {
+		sort.SliceStable(repos, func(i, j int) bool {
+			if sortBy == "activity" {
+				ti, tj := lastActivity(repos[i]), lastActivity(repos[j])
+				if !ti.Equal(tj) {
+					return ti.After(tj)
+				}
+			}
+			return repos[i].Name < repos[j].Name
+		})
+		groups = append(groups, repoIndexGroup{Name: name, Repos: repos})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name == "" || groups[j].Name == "" {
+			return groups[j].Name == "" && groups[i].Name != ""
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+func lastActivity(entry repoIndexEntry) time.Time {
+	if entry.Summary == nil || entry.Summary.LastCommit == nil {
+		return time.Time{}
+	}
+	return entry.Summary.LastCommit.Time
+}
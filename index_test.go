@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexHandlerGroupsAndFiltersRepos(t *testing.T) {
+	a := &app{
+		repos:     map[string]string{"team-a/api": "/a", "team-a/web": "/b", "tools": "/c", "secret": "/d"},
+		repoNames: []string{"team-a/api", "team-a/web", "tools", "secret"},
+		details: map[string]repoDetails{
+			"team-a/api": {Group: "Team A", Description: "Public HTTP API"},
+			"team-a/web": {Group: "Team A", Description: "Website"},
+		},
+		access: map[string]repoAccess{"secret": newRepoAccess([]string{"alice"}, nil)},
+	}
+
+	rr := httptest.NewRecorder()
+	a.indexHandler(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"<h3>Team A</h3>", "<h3>Other</h3>", `href="/repo/team-a/api/"`, "Public HTTP API", `href="/repo/tools/"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected index to contain %q, got body %q", want, body)
+		}
+	}
+	if strings.Index(body, "Team A") > strings.Index(body, "Other") {
+		t.Fatalf("expected repos without a group to be listed last")
+	}
+	if strings.Contains(body, `href="/repo/secret/"`) {
+		t.Fatalf("expected restricted repo to be hidden")
+	}
+
+	rr = httptest.NewRecorder()
+	a.indexHandler(rr, httptest.NewRequest("GET", "/?q=http", nil))
+	body = rr.Body.String()
+	if !strings.Contains(body, `href="/repo/team-a/api/"`) || strings.Contains(body, `href="/repo/team-a/web/"`) || strings.Contains(body, `href="/repo/tools/"`) {
+		t.Fatalf("expected search to only match the API repo, got body %q", body)
+	}
+
+	alice := context.WithValue(context.Background(), userContextKey{}, &user{Name: "alice"})
+	rr = httptest.NewRecorder()
+	a.indexHandler(rr, httptest.NewRequest("GET", "/", nil).WithContext(alice))
+	if !strings.Contains(rr.Body.String(), `href="/repo/secret/"`) {
+		t.Fatalf("expected alice to see the restricted repo")
+	}
+}
+
+func TestIndexHandlerSortsByActivityAndCachesSummaries(t *testing.T) {
+	recent, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+
+	old := t.TempDir()
+	runGitMainTest(t, old, "init")
+	writeFileMainTest(t, filepath.Join(old, "README"), "old\n")
+	runGitMainTest(t, old, "add", ".")
+	commit := exec.Command("git", "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-m", "ancient history")
+	commit.Dir = old
+	commit.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2001-01-01T00:00:00Z", "GIT_AUTHOR_DATE=2001-01-01T00:00:00Z")
+	if out, err := commit.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\n%s", err, out)
+	}
+
+	a := &app{
+		repos:     map[string]string{"a-old": old, "b-recent": recent},
+		repoNames: []string{"a-old", "b-recent"},
+	}
+
+	rr := httptest.NewRecorder()
+	a.indexHandler(rr, httptest.NewRequest("GET", "/?sort=activity", nil))
+	body := rr.Body.String()
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	if strings.Index(body, `href="/repo/b-recent/"`) > strings.Index(body, `href="/repo/a-old/"`) {
+		t.Fatalf("expected the recently active repo first")
+	}
+	for _, want := range []string{"ancient history", "moved Android app to a subfolder", "1 branch<"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected index to contain %q, got body %q", want, body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	a.indexHandler(rr, httptest.NewRequest("GET", "/?sort=name", nil))
+	body = rr.Body.String()
+	if strings.Index(body, `href="/repo/a-old/"`) > strings.Index(body, `href="/repo/b-recent/"`) {
+		t.Fatalf("expected repos sorted by name")
+	}
+	if _, ok := a.summaries.entries[old]; !ok {
+		t.Fatalf("expected repo summary to be cached")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlobAndRawServeLFSContent(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+	content := "real LFS content\n"
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+	writeFileMainTest(t, filepath.Join(repoPath, "asset.txt"), fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(content)))
+	writeFileMainTest(t, filepath.Join(repoPath, "absent.bin"), "version https://git-lfs.github.com/spec/v1\noid sha256:"+strings.Repeat("a", 64)+"\nsize 2048\n")
+	runGitMainTest(t, repoPath, "add", ".")
+	runGitMainTest(t, repoPath, "commit", "-q", "-m", "add LFS files")
+	writeFileMainTest(t, filepath.Join(repoPath, ".git", "lfs", "objects", oid[:2], oid[2:4], oid), content)
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+	router := a.routes()
+	get := func(path string) string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: unexpected status code: got %d want 200", path, rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	body := get("/repo/testrepo/blob/HEAD/asset.txt")
+	for _, want := range []string{"Stored with Git LFS", "17 B", "sha256:" + oid[:12], "real LFS content"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected blob view to contain %q, got body %q", want, body)
+		}
+	}
+	if strings.Contains(body, "not in this repository") {
+		t.Fatalf("expected local LFS object to be found, got body %q", body)
+	}
+	if raw := get("/repo/testrepo/raw/HEAD/asset.txt"); raw != content {
+		t.Fatalf("unexpected raw content %q", raw)
+	}
+
+	body = get("/repo/testrepo/blob/HEAD/absent.bin")
+	if !strings.Contains(body, "2.0 KiB") || !strings.Contains(body, "local LFS store, so the pointer file is shown") || !strings.Contains(body, "size 2048") {
+		t.Fatalf("expected pointer to be shown for missing LFS object, got body %q", body)
+	}
+	if raw := get("/repo/testrepo/raw/HEAD/absent.bin"); !strings.HasPrefix(raw, "version https://git-lfs.github.com/spec/v1") {
+		t.Fatalf("expected raw pointer for missing LFS object, got %q", raw)
+	}
+
+	// The pointer is served until the object is fetched, and a local object
+	// could be replaced, so neither may be cached for good.
+	head := runGitMainTest(t, repoPath, "rev-parse", "HEAD")
+	for path, immutable := range map[string]bool{"asset.txt": false, "absent.bin": false, "Android/androidApp/src/main/java/com/example/loclogger/MainActivity.kt": true} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/repo/testrepo/raw/"+head+"/"+path, nil))
+		if got := strings.Contains(rr.Header().Get("Cache-Control"), "immutable"); got != immutable {
+			t.Fatalf("%s: expected immutable=%v, got Cache-Control %q", path, immutable, rr.Header().Get("Cache-Control"))
+		}
+	}
+
+	// A damaged object is found out only once it was sent, so the response
+	// is aborted rather than completed.
+	writeFileMainTest(t, filepath.Join(repoPath, ".git", "lfs", "objects", oid[:2], oid[2:4], oid), strings.ToUpper(content))
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Fatalf("expected the damaged LFS object to abort the response, got %v", recovered)
+		}
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/repo/testrepo/raw/HEAD/asset.txt", nil))
+}
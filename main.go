@@ -1,17 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -24,17 +33,76 @@ var templates *template.Template
 
 type appConfig struct {
 	Repos []repoConfig `json:"repos"`
+	// ScanInterval is how often scan roots are searched for new or removed
+	// repositories, e.g. "5m". Zero uses the default, "0" disables rescans.
+	ScanInterval string     `json:"scanInterval"`
+	Git          gitConfig  `json:"git"`
+	Auth         authConfig `json:"auth"`
+}
+
+type gitConfig struct {
+	// CommandTimeout limits each git invocation, e.g. "30s". Empty uses the default.
+	CommandTimeout string `json:"commandTimeout"`
+	// Output limits in bytes for content rendered in pages. Zero uses the default.
+	MaxBlobBytes int64 `json:"maxBlobBytes"`
+	MaxDiffBytes int64 `json:"maxDiffBytes"`
+	MaxLogBytes  int64 `json:"maxLogBytes"`
+	// CacheBytes bounds the in-memory cache of trees, blobs, diffs and
+	// histories. Zero uses the default, a negative value disables it.
+	CacheBytes int64 `json:"cacheBytes"`
+	// Concurrency limits for git subprocesses. Zero uses the default.
+	MaxProcesses        int    `json:"maxProcesses"`
+	MaxProcessesPerRepo int    `json:"maxProcessesPerRepo"`
+	QueueTimeout        string `json:"queueTimeout"`
 }
 
 type repoConfig struct {
+	// Name may be hierarchical, like "team-a/service".
 	Name string `json:"name"`
 	Path string `json:"path"`
+	// Group and Description are shown on the repository index page.
+	Group       string `json:"group"`
+	Description string `json:"description"`
+	// Users and Groups restrict who may read the repo. Empty means everyone.
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+
+	// Scan replaces Name and Path: every repository found below this
+	// directory, at most Depth levels down, is added. Relative paths matching
+	// one of the Exclude globs are skipped.
+	Scan    string   `json:"scan"`
+	Depth   int      `json:"depth"`
+	Exclude []string `json:"exclude"`
+
+	// Worktrees adds the linked worktrees of the repo as entries named
+	// "<name>/<directory>", so their uncommitted changes can be browsed.
+	Worktrees bool `json:"worktrees"`
+
+	// discovered is set for repos found by a scan, which are skipped instead
+	// of failing the config when they turn out to be unusable.
+	discovered bool
 }
 
 type app struct {
-	repos       map[string]string
-	repoNames   []string
-	defaultRepo string
+	// mu guards the repository set, which is replaced when the config is
+	// reloaded.
+	mu        sync.RWMutex
+	repos     map[string]string
+	repoNames []string
+	details   map[string]repoDetails
+	access    map[string]repoAccess
+	auth      *authenticator
+	// scanInterval is how often the config is reloaded to rescan scan roots,
+	// zero if there are none.
+	scanInterval time.Duration
+	summaries    summaryCache
+}
+
+// repoDetails holds the descriptive config of a repository.
+type repoDetails struct {
+	Group       string
+	Description string
+	Bare        bool
 }
 
 type baseViewData struct {
@@ -42,6 +110,22 @@ type baseViewData struct {
 	Repos    []string
 	Rev      string
 	Branches []string
+	User     *user
+	// WorkTree is set for repos with a work tree, whose changes can be shown.
+	WorkTree bool
+}
+
+type commitViewData struct {
+	baseViewData
+	// Title replaces the default "Commit <hash>" heading.
+	Title    string
+	Hash     string
+	Diff     string
+	Path     string
+	Parents  []string
+	DiffMode string
+	TooLarge bool
+	RawURL   string
 }
 
 func init() {
@@ -58,6 +142,8 @@ func init() {
 			}
 			return s[start:end]
 		},
+		"timeAgo":  timeAgo,
+		"byteSize": byteSize,
 	}
 
 	var err error
@@ -74,29 +160,14 @@ func main() {
 		log.Fatal(err)
 	}
 
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-
-	workDir := "."
-	filesDir := http.Dir(filepath.Join(workDir, "static"))
-	FileServer(r, "/static", filesDir)
-
-	r.Get("/", application.rootHandler)
-	r.Get("/repo/{repo}", application.repoIndexHandler)
-	r.Get("/repo/{repo}/", application.repoIndexHandler)
-	r.Get("/repo/{repo}/tree/{rev}", application.treeHandler)
-	r.Get("/repo/{repo}/tree/{rev}/*", application.treeHandler)
-	r.Get("/repo/{repo}/blob/{rev}/*", application.blobHandler)
-	r.Get("/repo/{repo}/file-history/{rev}/*", application.fileHistoryHandler)
-	r.Get("/repo/{repo}/file-diff/{hash}/*", application.fileDiffHandler)
-	r.Get("/repo/{repo}/commits", application.commitsHandler)
-	r.Get("/repo/{repo}/commits/{rev}", application.commitsHandler)
-	r.Get("/repo/{repo}/commit/{hash}", application.commitHandler)
-
+	// Request contexts derive from baseCtx, so cancelling it on shutdown kills
+	// git commands still running for requests in flight.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+		Addr:        ":8080",
+		Handler:     application.routes(),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
 
 	go func() {
@@ -106,40 +177,131 @@ func main() {
 		}
 	}()
 
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	go application.watchConfig(watchCtx, configPath(), configPollInterval)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopWatching()
+	cancelRequests()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
+	err = srv.Shutdown(ctx)
+	git.CloseObjectReaders()
+	if err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
 	log.Println("Server exiting")
 }
 
+// metricsHandler publishes the git process limiter metrics in the format of
+// expvar.Handler. The rest of the expvar set, such as the command line and
+// memory statistics, is left out.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, "{\n\"git\": %s\n}\n", git.Metrics())
+}
+
+// routes returns the router serving all pages.
+func (a *app) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(a.authMiddleware)
+
+	workDir := "."
+	filesDir := http.Dir(filepath.Join(workDir, "static"))
+	FileServer(r, "/static", filesDir)
+
+	r.NotFound(a.notFound)
+	r.Get("/debug/vars", metricsHandler)
+	if a.auth != nil && a.auth.oidc != nil {
+		r.Get("/auth/login", a.auth.oidc.loginHandler)
+		r.Get("/auth/callback", a.auth.oidc.callbackHandler)
+		r.Post("/auth/logout", a.auth.oidc.logoutHandler)
+	}
+	r.Get("/", a.indexHandler)
+
+	// Repo names may contain slashes, so the pages of a repo are routed
+	// relative to it once dispatchRepo has found its name.
+	pages := chi.NewRouter()
+	pages.NotFound(a.notFound)
+	pages.Get("/", a.repoIndexHandler)
+	pages.Get("/tree/{rev}", a.treeHandler)
+	pages.Get("/tree/{rev}/*", a.treeHandler)
+	pages.Get("/blob/{rev}/*", a.blobHandler)
+	pages.Get("/raw/{rev}/*", a.rawHandler)
+	pages.Get("/file-history/{rev}/*", a.fileHistoryHandler)
+	pages.Get("/file-diff/{hash}/*", a.fileDiffHandler)
+	pages.Get("/commits", a.commitsHandler)
+	pages.Get("/commits/{rev}", a.commitsHandler)
+	pages.Get("/commit/{hash}", a.commitHandler)
+	pages.Get("/status", a.statusHandler)
+	pages.Get("/status/*", a.statusFileHandler)
+	pages.Get("/stash", a.stashListHandler)
+	pages.Get("/stash/{hash}", a.stashHandler)
+	pages.Get("/reflog", a.reflogHandler)
+	pages.Get("/reflog/*", a.reflogHandler)
+	r.Get("/repo/*", a.dispatchRepo(pages))
+
+	return r
+}
+
 func loadApp() (*app, error) {
-	cfgPath := os.Getenv("GITBROWSER_CONFIG")
-	if cfgPath == "" {
-		cfgPath = "repos.json"
+	config, err := loadConfig(configPath())
+	if err != nil {
+		return nil, err
 	}
 
-	config, err := loadConfig(cfgPath)
+	auth, err := newAuthenticator(context.Background(), config.Auth)
+	if err != nil {
+		return nil, err
+	}
+	application, err := newApp(config, auth)
 	if err != nil {
 		return nil, err
 	}
+	if err := applyGitConfig(config.Git); err != nil {
+		return nil, err
+	}
+	return application, nil
+}
 
-	repos := make(map[string]string, len(config.Repos))
-	repoNames := make([]string, 0, len(config.Repos))
-	for _, repo := range config.Repos {
+// configPath returns the config file named by GITBROWSER_CONFIG, or repos.json.
+func configPath() string {
+	if path := os.Getenv("GITBROWSER_CONFIG"); path != "" {
+		return path
+	}
+	return "repos.json"
+}
+
+// newApp validates the repositories in config and returns an app serving them.
+func newApp(config appConfig, auth *authenticator) (*app, error) {
+	entries, scanInterval, err := expandScans(config)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[string]string, len(entries))
+	repoNames := make([]string, 0, len(entries))
+	details := make(map[string]repoDetails, len(entries))
+	access := make(map[string]repoAccess, len(entries))
+	for _, repo := range entries {
 		name := strings.TrimSpace(repo.Name)
 		if name == "" {
 			return nil, errors.New("all repos must have a non-empty name")
 		}
-		if strings.Contains(name, "/") {
-			return nil, fmt.Errorf("repo name %q cannot contain '/'", name)
+		if err := validateRepoName(name); err != nil {
+			if repo.discovered {
+				log.Printf("Skipping discovered repo %q: %v", repo.Path, err)
+				continue
+			}
+			return nil, err
 		}
 		if _, exists := repos[name]; exists {
 			return nil, fmt.Errorf("repo name %q is duplicated", name)
@@ -160,25 +322,140 @@ func loadApp() (*app, error) {
 		if !info.IsDir() {
 			return nil, fmt.Errorf("repo %q path %q is not a directory", name, absPath)
 		}
-		if err := git.ValidateRepository(absPath); err != nil {
-			return nil, fmt.Errorf("repo %q path %q is not a valid git working tree: %w", name, absPath, err)
+		if err := git.ValidateRepository(context.Background(), absPath); err != nil {
+			if repo.discovered {
+				log.Printf("Skipping discovered repo %q: %v", absPath, err)
+				continue
+			}
+			return nil, fmt.Errorf("repo %q path %q is not a git repository: %w", name, absPath, err)
 		}
 
 		repos[name] = absPath
 		repoNames = append(repoNames, name)
+		bare, err := git.IsBareRepository(context.Background(), absPath)
+		if err != nil {
+			return nil, fmt.Errorf("repo %q path %q: %w", name, absPath, err)
+		}
+		details[name] = repoDetails{
+			Group:       strings.TrimSpace(repo.Group),
+			Description: strings.TrimSpace(repo.Description),
+			Bare:        bare,
+		}
+		access[name] = newRepoAccess(repo.Users, repo.Groups)
 	}
 
 	if len(repoNames) == 0 {
 		return nil, errors.New("config must include at least one repo")
 	}
 
+	if auth == nil {
+		for _, name := range repoNames {
+			if access[name].restricted() {
+				return nil, fmt.Errorf("repo %q restricts users or groups, which requires auth to be configured", name)
+			}
+		}
+	}
+
 	return &app{
-		repos:       repos,
-		repoNames:   repoNames,
-		defaultRepo: repoNames[0],
+		repos:        repos,
+		repoNames:    repoNames,
+		details:      details,
+		access:       access,
+		auth:         auth,
+		scanInterval: scanInterval,
 	}, nil
 }
 
+// expandScans replaces scan entries in config with the repositories they
+// find, and adds the linked worktrees of entries with Worktrees set.
+// Discovered repos never take the name of a configured one; clashes get a
+// numeric suffix.
+func expandScans(config appConfig) ([]repoConfig, time.Duration, error) {
+	taken := make(map[string]bool)
+	scans := false
+	for _, repo := range config.Repos {
+		if repo.Scan == "" {
+			taken[strings.TrimSpace(repo.Name)] = true
+		}
+		if repo.Scan != "" || repo.Worktrees {
+			scans = true
+		}
+	}
+
+	var scanInterval time.Duration
+	if scans {
+		scanInterval = defaultScanInterval
+		if config.ScanInterval != "" {
+			interval, err := time.ParseDuration(config.ScanInterval)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid scanInterval %q: %w", config.ScanInterval, err)
+			}
+			scanInterval = interval
+		}
+	}
+
+	entries := make([]repoConfig, 0, len(config.Repos))
+	for _, repo := range config.Repos {
+		var found []repoConfig
+		if repo.Scan == "" {
+			entries = append(entries, repo)
+			if !repo.Worktrees {
+				continue
+			}
+			found = discoverWorktrees(repo)
+		} else {
+			var err error
+			found, err = discoverRepos(repo)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		for _, discovered := range found {
+			discovered.Name = uniqueRepoName(discovered.Name, taken)
+			taken[discovered.Name] = true
+			entries = append(entries, discovered)
+		}
+	}
+	return entries, scanInterval, nil
+}
+
+// applyGitConfig validates the git settings and then applies them.
+func applyGitConfig(cfg gitConfig) error {
+	var err error
+	commandTimeout := git.DefaultCommandTimeout
+	if cfg.CommandTimeout != "" {
+		commandTimeout, err = time.ParseDuration(cfg.CommandTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid git.commandTimeout %q: %w", cfg.CommandTimeout, err)
+		}
+	}
+	var queueTimeout time.Duration
+	if cfg.QueueTimeout != "" {
+		queueTimeout, err = time.ParseDuration(cfg.QueueTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid git.queueTimeout %q: %w", cfg.QueueTimeout, err)
+		}
+	}
+	cacheBytes := cfg.CacheBytes
+	if cacheBytes == 0 {
+		cacheBytes = git.DefaultCacheBytes
+	}
+
+	git.SetCommandTimeout(commandTimeout)
+	git.SetConcurrency(git.Concurrency{
+		MaxProcesses:        cfg.MaxProcesses,
+		MaxProcessesPerRepo: cfg.MaxProcessesPerRepo,
+		QueueTimeout:        queueTimeout,
+	})
+	git.SetCacheSize(cacheBytes)
+	git.SetLimits(git.Limits{
+		MaxBlobBytes: cfg.MaxBlobBytes,
+		MaxDiffBytes: cfg.MaxDiffBytes,
+		MaxLogBytes:  cfg.MaxLogBytes,
+	})
+	return nil
+}
+
 func loadConfig(path string) (appConfig, error) {
 	var cfg appConfig
 
@@ -193,28 +470,93 @@ func loadConfig(path string) (appConfig, error) {
 	return cfg, nil
 }
 
+// pageNames are the first path segments of the pages of a repository.
+var pageNames = []string{"tree", "blob", "raw", "file-history", "file-diff", "commits", "commit", "status", "stash", "reflog"}
+
+// validateRepoName allows hierarchical names like "team-a/service" as long as
+// they stay usable as a URL path. Below the first segment, page names are not
+// allowed: URLs go to the longest matching repo name, so "team/tree" would
+// hide the file pages of a repo named "team".
+func validateRepoName(name string) error {
+	if strings.ContainsAny(name, "?#%\\") {
+		return fmt.Errorf("repo name %q cannot contain '?', '#', '%%' or '\\'", name)
+	}
+	for i, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("repo name %q cannot contain empty, '.' or '..' path segments", name)
+		}
+		if i > 0 && slices.Contains(pageNames, segment) {
+			return fmt.Errorf("repo name %q cannot contain the page name %q below its first segment", name, segment)
+		}
+	}
+	return nil
+}
+
+// dispatchRepo serves /repo/<name>/<page>. The name is the longest configured
+// repo name the path starts with; the rest is routed by pages, the way a
+// mounted chi router would see it.
+func (a *app) dispatchRepo(pages http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		name, rest, ok := a.matchRepo(chi.URLParam(r, "*"))
+		if !ok {
+			a.notFound(w, r)
+			return
+		}
+
+		if n := len(rctx.URLParams.Keys) - 1; n >= 0 && rctx.URLParams.Keys[n] == "*" {
+			rctx.URLParams.Values[n] = ""
+		}
+		rctx.URLParams.Add("repo", name)
+		rctx.RoutePath = "/" + rest
+		pages.ServeHTTP(w, r)
+	}
+}
+
+// matchRepo splits path into the longest repo name it starts with and the
+// remaining page path.
+func (a *app) matchRepo(path string) (name, rest string, ok bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	segments := strings.Split(path, "/")
+	for i := len(segments); i > 0; i-- {
+		name = strings.Join(segments[:i], "/")
+		if _, ok := a.repos[name]; ok {
+			return name, strings.Join(segments[i:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
 func (a *app) repoPathFromRequest(w http.ResponseWriter, r *http.Request) (string, string, bool) {
 	repoName := chi.URLParam(r, "repo")
-	repoPath, ok := a.repos[repoName]
+	repoPath, ok := a.readableRepoPath(r.Context(), repoName)
+	// Repos the user may not read are indistinguishable from missing ones.
 	if !ok {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return "", "", false
 	}
 	return repoName, repoPath, true
 }
 
-func (a *app) baseData(repoName, repoPath, rev string) baseViewData {
-	branches, _ := git.GetBranches(repoPath)
+func (a *app) baseData(ctx context.Context, repoName, repoPath, rev string) baseViewData {
+	branches, _ := git.GetBranches(ctx, repoPath)
 	return baseViewData{
 		Repo:     repoName,
-		Repos:    a.repoNames,
+		Repos:    a.visibleRepos(ctx),
 		Rev:      rev,
 		Branches: branches,
+		User:     userFromContext(ctx),
+		WorkTree: !a.repoDetails(repoName).Bare,
 	}
 }
 
-func (a *app) rootHandler(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, "/repo/"+a.defaultRepo+"/", http.StatusFound)
+// repoDetails returns the descriptive config of repoName.
+func (a *app) repoDetails(repoName string) repoDetails {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.details[repoName]
 }
 
 func (a *app) repoIndexHandler(w http.ResponseWriter, r *http.Request) {
@@ -222,8 +564,9 @@ func (a *app) repoIndexHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
-	currentBranch, err := git.GetCurrentBranch(repoPath)
+	currentBranch, err := git.GetDefaultBranch(ctx, repoPath)
 	if err != nil || currentBranch == "" {
 		currentBranch = "HEAD"
 	}
@@ -235,27 +578,51 @@ func (a *app) treeHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
 	rev := chi.URLParam(r, "rev")
 	path := chi.URLParam(r, "*")
 
-	entries, err := git.ListTree(repoPath, rev, path)
+	commit, err := git.ResolveRevision(ctx, repoPath, rev)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		a.renderError(w, r, err)
 		return
 	}
+	entries, err := git.ListTree(ctx, repoPath, commit, path)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+	lastCommits, err := git.GetLastCommits(ctx, repoPath, rev, path, names)
+	if err != nil {
+		log.Printf("last commits for %s:%s: %v", rev, path, err)
+	}
 
 	data := struct {
 		baseViewData
-		Path    string
-		Entries []git.TreeEntry
+		Path        string
+		Entries     []git.TreeEntry
+		LastCommits map[string]git.LastCommit
+		Submodules  map[string]submoduleView
+		Symlinks    map[string]symlinkView
+		Worktrees   []worktreeView
 	}{
-		baseViewData: a.baseData(repoName, repoPath, rev),
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
 		Path:         path,
 		Entries:      entries,
+		LastCommits:  lastCommits,
+		Submodules:   a.submodules(ctx, repoPath, commit, entries),
+		Symlinks:     symlinks(ctx, repoName, repoPath, rev, commit, entries),
 	}
-
-	render(w, "tree.html", data)
+	if path == "" {
+		data.Worktrees = a.worktrees(ctx, repoPath)
+	}
+	render(w, r, "tree.html", data)
 }
 
 func (a *app) blobHandler(w http.ResponseWriter, r *http.Request) {
@@ -263,34 +630,112 @@ func (a *app) blobHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
 	rev := chi.URLParam(r, "rev")
 	path := chi.URLParam(r, "*")
 	normalizedPath, ok := normalizeRepoRelativePath(repoPath, path)
 	if !ok {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
-	content, err := git.GetFileContent(repoPath, rev, normalizedPath)
+	file, err := git.StatFile(ctx, repoPath, rev, normalizedPath)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		a.renderError(w, r, err)
 		return
 	}
 
-	lines := strings.Split(content, "\n")
+	// A symlink's content is its target, which is shown as a link instead.
+	// LFS pointers are replaced by the real content when it is available.
+	var symlink *git.Symlink
+	var lfs *git.LFSPointer
+	if file.IsSymlink() {
+		symlink, err = git.ResolveSymlink(ctx, repoPath, file.Commit, file.TreeEntry)
+	} else {
+		lfs, err = git.GetLFSPointer(ctx, repoPath, file)
+	}
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	var lines []string
+	tooLarge := false
+	if symlink == nil {
+		var content string
+		if lfs != nil && lfs.Local {
+			content, err = git.GetLFSContent(ctx, repoPath, *lfs)
+		} else {
+			content, err = git.GetFileContent(ctx, repoPath, file.Commit, normalizedPath)
+		}
+		tooLarge = errors.Is(err, git.ErrTooLarge)
+		if err != nil && !tooLarge {
+			a.renderError(w, r, err)
+			return
+		}
+		if !tooLarge {
+			lines = strings.Split(content, "\n")
+		}
+	}
 
 	data := struct {
 		baseViewData
-		Path  string
-		Lines []string
+		Path     string
+		Lines    []string
+		TooLarge bool
+		Symlink  *symlinkView
+		LFS      *git.LFSPointer
 	}{
-		baseViewData: a.baseData(repoName, repoPath, rev),
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
 		Path:         normalizedPath,
 		Lines:        lines,
+		TooLarge:     tooLarge,
+		LFS:          lfs,
 	}
+	if symlink != nil {
+		data.Symlink = &symlinkView{Symlink: symlink, Repo: repoName, Rev: rev}
+	}
+	render(w, r, "blob.html", data)
+}
 
-	render(w, "blob.html", data)
+func (a *app) rawHandler(w http.ResponseWriter, r *http.Request) {
+	_, repoPath, ok := a.repoPathFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	rev := chi.URLParam(r, "rev")
+	normalizedPath, ok := normalizeRepoRelativePath(repoPath, chi.URLParam(r, "*"))
+	if !ok {
+		a.notFound(w, r)
+		return
+	}
+
+	file, err := git.StatFile(ctx, repoPath, rev, normalizedPath)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+	// LFS pointers are served with the real content when it is available.
+	lfs, err := git.GetLFSPointer(ctx, repoPath, file)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	// LFS content is only verified while it is sent, and a pointer whose
+	// object is fetched or repaired later will be served differently.
+	if lfs == nil {
+		markImmutable(w, r, rev)
+	}
+	a.stream(w, r, "", func(w io.Writer) error {
+		if lfs != nil && lfs.Local {
+			return git.WriteLFSContent(ctx, repoPath, w, *lfs)
+		}
+		return git.WriteFileContent(ctx, repoPath, w, file.Commit, normalizedPath)
+	})
 }
 
 func (a *app) commitsHandler(w http.ResponseWriter, r *http.Request) {
@@ -298,27 +743,43 @@ func (a *app) commitsHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
 	rev := chi.URLParam(r, "rev")
 	if rev == "" {
-		rev, _ = git.GetCurrentBranch(repoPath)
+		rev, _ = git.GetDefaultBranch(ctx, repoPath)
 	}
+	allBranches := r.URL.Query().Get("branches") == "all"
 
-	commits, err := git.GetLog(repoPath, rev)
+	var commits []git.LogEntry
+	var err error
+	if allBranches {
+		commits, err = git.GetAllBranchesLog(ctx, repoPath)
+	} else {
+		commits, err = git.GetLog(ctx, repoPath, rev)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		a.renderError(w, r, err)
 		return
 	}
 
+	rows := git.BuildGraph(commits)
+	entries := make([]commitGraphEntry, len(commits))
+	for i, commit := range commits {
+		entries[i] = commitGraphEntry{LogEntry: commit, Graph: newGraphRowView(rows[i])}
+	}
+
 	data := struct {
 		baseViewData
-		Commits []git.LogEntry
+		Commits     []commitGraphEntry
+		AllBranches bool
 	}{
-		baseViewData: a.baseData(repoName, repoPath, rev),
-		Commits:      commits,
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
+		Commits:      entries,
+		AllBranches:  allBranches,
 	}
 
-	render(w, "commits.html", data)
+	render(w, r, "commits.html", data)
 }
 
 func (a *app) commitHandler(w http.ResponseWriter, r *http.Request) {
@@ -326,30 +787,66 @@ func (a *app) commitHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
 	hash := chi.URLParam(r, "hash")
+	raw := r.URL.Query().Get("format") == "raw"
 
-	diff, err := git.GetCommitDiff(repoPath, hash)
+	parents, err := git.GetCommitParents(ctx, repoPath, hash)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		a.renderError(w, r, err)
 		return
 	}
 
-	rev, _ := git.GetCurrentBranch(repoPath)
+	// Merge commits can be viewed against each parent or in combined form.
+	var diff, diffMode string
+	if len(parents) > 1 {
+		diffMode = r.URL.Query().Get("diff")
+		if diffMode == "" {
+			diffMode = git.MergeDiffDenseCombined
+		}
+		if !validMergeDiffMode(diffMode, len(parents)) {
+			a.notFound(w, r)
+			return
+		}
+	}
 
-	data := struct {
-		baseViewData
-		Hash string
-		Diff string
-		Path string
-	}{
-		baseViewData: a.baseData(repoName, repoPath, rev),
+	if raw {
+		markImmutable(w, r, hash)
+		a.stream(w, r, "text/plain; charset=utf-8", func(w io.Writer) error {
+			return git.WriteCommitDiff(ctx, repoPath, w, hash, diffMode, "")
+		})
+		return
+	}
+
+	if diffMode != "" {
+		diff, err = git.GetMergeDiff(ctx, repoPath, hash, diffMode)
+	} else {
+		diff, err = git.GetCommitDiff(ctx, repoPath, hash)
+	}
+	tooLarge := errors.Is(err, git.ErrTooLarge)
+	if err != nil && !tooLarge {
+		a.renderError(w, r, err)
+		return
+	}
+
+	rev, _ := git.GetDefaultBranch(ctx, repoPath)
+
+	rawURL := "/repo/" + repoName + "/commit/" + hash + "?format=raw"
+	if diffMode != "" {
+		rawURL += "&diff=" + url.QueryEscape(diffMode)
+	}
+	data := commitViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
 		Hash:         hash,
 		Diff:         diff,
 		Path:         "",
+		Parents:      parents,
+		DiffMode:     diffMode,
+		TooLarge:     tooLarge,
+		RawURL:       rawURL,
 	}
-
-	render(w, "commit.html", data)
+	render(w, r, "commit.html", data)
 }
 
 func (a *app) fileHistoryHandler(w http.ResponseWriter, r *http.Request) {
@@ -357,18 +854,19 @@ func (a *app) fileHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
 	rev := chi.URLParam(r, "rev")
 	path := chi.URLParam(r, "*")
 	normalizedPath, pathOK := normalizeRepoRelativePath(repoPath, path)
 	if !pathOK {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
-	commits, err := git.GetFileHistory(repoPath, rev, normalizedPath)
+	commits, err := git.GetFileHistory(ctx, repoPath, rev, normalizedPath)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		a.renderError(w, r, err)
 		return
 	}
 
@@ -377,12 +875,11 @@ func (a *app) fileHistoryHandler(w http.ResponseWriter, r *http.Request) {
 		Path    string
 		Commits []git.FileHistoryEntry
 	}{
-		baseViewData: a.baseData(repoName, repoPath, rev),
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
 		Path:         normalizedPath,
 		Commits:      commits,
 	}
-
-	render(w, "file_history.html", data)
+	render(w, r, "file_history.html", data)
 }
 
 func (a *app) fileDiffHandler(w http.ResponseWriter, r *http.Request) {
@@ -390,27 +887,40 @@ func (a *app) fileDiffHandler(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	ctx := r.Context()
 
 	hash := chi.URLParam(r, "hash")
 	path := chi.URLParam(r, "*")
 	normalizedPath, pathOK := normalizeRepoRelativePath(repoPath, path)
 	if !pathOK {
-		http.NotFound(w, r)
+		a.notFound(w, r)
 		return
 	}
 
-	diff, err := git.GetCommitFileDiff(repoPath, hash, normalizedPath)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if r.URL.Query().Get("format") == "raw" {
+		markImmutable(w, r, hash)
+		a.stream(w, r, "text/plain; charset=utf-8", func(w io.Writer) error {
+			return git.WriteCommitDiff(ctx, repoPath, w, hash, "", normalizedPath)
+		})
+		return
+	}
+
+	diff, err := git.GetCommitFileDiff(ctx, repoPath, hash, normalizedPath)
+	tooLarge := errors.Is(err, git.ErrTooLarge)
+	if err != nil && !tooLarge {
+		a.renderError(w, r, err)
 		return
 	}
-	if strings.TrimSpace(diff) == "" {
-		// If file path changed over time, resolve the path at this commit and retry.
-		history, historyErr := git.GetFileHistory(repoPath, "HEAD", normalizedPath)
+	rev, revErr := git.GetDefaultBranch(ctx, repoPath)
+	if !tooLarge && strings.TrimSpace(diff) == "" && revErr == nil {
+		// If file path changed over time, resolve the path at this commit and
+		// retry. The path is taken to be current on the branch the page links
+		// back to.
+		history, historyErr := git.GetFileHistory(ctx, repoPath, rev, normalizedPath)
 		if historyErr == nil {
 			for _, entry := range history {
 				if entry.Hash == hash && entry.Path != normalizedPath {
-					if retryDiff, retryErr := git.GetCommitFileDiff(repoPath, hash, entry.Path); retryErr == nil {
+					if retryDiff, retryErr := git.GetCommitFileDiff(ctx, repoPath, hash, entry.Path); retryErr == nil {
 						diff = retryDiff
 						normalizedPath = entry.Path
 					}
@@ -420,27 +930,134 @@ func (a *app) fileDiffHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rev, _ := git.GetCurrentBranch(repoPath)
-	data := struct {
-		baseViewData
-		Hash string
-		Diff string
-		Path string
-	}{
-		baseViewData: a.baseData(repoName, repoPath, rev),
+	data := commitViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
 		Hash:         hash,
 		Diff:         diff,
 		Path:         normalizedPath,
+		TooLarge:     tooLarge,
+		RawURL:       "/repo/" + repoName + "/file-diff/" + hash + "/" + normalizedPath + "?format=raw",
 	}
+	render(w, r, "commit.html", data)
+}
 
-	render(w, "commit.html", data)
+// validMergeDiffMode reports whether mode selects a merge diff form or one of
+// the commit's parents.
+func validMergeDiffMode(mode string, parents int) bool {
+	if mode == git.MergeDiffDenseCombined || mode == git.MergeDiffCombined {
+		return true
+	}
+	n, err := strconv.Atoi(mode)
+	return err == nil && n >= 1 && n <= parents && strconv.Itoa(n) == mode
 }
 
-func render(w http.ResponseWriter, name string, data interface{}) {
-	err := templates.ExecuteTemplate(w, name, data)
-	if err != nil {
+// stream writes the output of write directly to the client. Errors that occur
+// before anything was sent are rendered as usual; later ones are logged and
+// abort the response.
+// An empty contentType is sniffed from the first bytes written.
+func (a *app) stream(w http.ResponseWriter, r *http.Request, contentType string, write func(io.Writer) error) {
+	sw := &streamWriter{w: w, contentType: contentType}
+	if err := write(sw); err != nil {
+		if !sw.started {
+			a.renderError(w, r, err)
+			return
+		}
+		// Drop the connection so the client does not take the partial or
+		// unverified body for a complete one.
+		log.Printf("%s %s: stream aborted: %v", r.Method, r.URL.Path, err)
+		panic(http.ErrAbortHandler)
+	}
+	if !sw.started {
+		sw.writeHeader(nil)
+	}
+}
+
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.writeHeader(p)
+	}
+	return sw.w.Write(p)
+}
+
+func (sw *streamWriter) writeHeader(first []byte) {
+	sw.started = true
+	contentType := sw.contentType
+	if contentType == "" {
+		// Raw content is never rendered as HTML on our origin.
+		contentType = "application/octet-stream"
+		if strings.HasPrefix(http.DetectContentType(first), "text/") {
+			contentType = "text/plain; charset=utf-8"
+		}
+	}
+	sw.w.Header().Set("Content-Type", contentType)
+	sw.w.Header().Set("X-Content-Type-Options", "nosniff")
+	sw.w.WriteHeader(http.StatusOK)
+}
+
+// render executes a page template and sends it with a strong ETag, answering
+// conditional requests for an unchanged page with 304 Not Modified.
+func render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	var body bytes.Buffer
+	if err := templates.ExecuteTemplate(&body, name, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = body.WriteTo(w)
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// markImmutable lets clients cache the response forever when rev is a full
+// object ID. It is only meant for raw content that depends on nothing but the
+// object; rendered pages also show branches, repos and other state that
+// changes, and are revalidated through their ETag instead. With auth enabled
+// the response is private, so shared caches never hand out a restricted
+// repository's content.
+func markImmutable(w http.ResponseWriter, r *http.Request, rev string) {
+	if !isFullHash(rev) {
+		return
+	}
+	scope := "public"
+	if userFromContext(r.Context()) != nil {
+		scope = "private"
+	}
+	w.Header().Set("Cache-Control", scope+", max-age=31536000, immutable")
+}
+
+// isFullHash reports whether rev is a complete SHA-1 or SHA-256 object ID.
+func isFullHash(rev string) bool {
+	if len(rev) != 40 && len(rev) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(rev)
+	return err == nil
 }
 
 func normalizeRepoRelativePath(repoPath, requestedPath string) (string, bool) {
@@ -469,6 +1086,50 @@ func normalizeRepoRelativePath(repoPath, requestedPath string) (string, bool) {
 	return path, true
 }
 
+// timeAgo formats t relative to now, e.g. "3 days ago".
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	if d < time.Minute {
+		return "just now"
+	}
+
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if n := int(d / unit.size); n >= 1 {
+			if n == 1 {
+				return "1 " + unit.name + " ago"
+			}
+			return fmt.Sprintf("%d %ss ago", n, unit.name)
+		}
+	}
+	return "just now"
+}
+
+// byteSize formats n bytes for display, e.g. "1.5 MiB".
+func byteSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / 1024
+	for _, unit := range []string{"KiB", "MiB", "GiB"} {
+		if size < 1024 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.1f TiB", size)
+}
+
 // FileServer conveniently sets up a http.FileServer handler to serve
 // static files from a http.FileSystem.
 func FileServer(r chi.Router, path string, root http.FileSystem) {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
@@ -30,9 +32,8 @@ func TestFileDiffHandlerFallsBackToHistoricalPath(t *testing.T) {
 	repoPath, hashSwitch, _, _, newPath := setupRepoWithRenamedFileForMainTests(t)
 
 	a := &app{
-		repos:       map[string]string{"testrepo": repoPath},
-		repoNames:   []string{"testrepo"},
-		defaultRepo: "testrepo",
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
 	}
 
 	req := httptest.NewRequest("GET", "/repo/testrepo/file-diff/"+hashSwitch+"/"+newPath, nil)
@@ -57,6 +58,124 @@ func TestFileDiffHandlerFallsBackToHistoricalPath(t *testing.T) {
 	}
 }
 
+func TestCommitHandlerRejectsOptionLikeHash(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+
+	hash := "--output=" + filepath.Join(t.TempDir(), "x")
+	req := httptest.NewRequest("GET", "/repo/testrepo/commit/x", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("repo", "testrepo")
+	rctx.URLParams.Add("hash", hash)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	a.commitHandler(rr, req)
+
+	if rr.Code != 404 {
+		t.Fatalf("unexpected status code: got %d want 404", rr.Code)
+	}
+}
+
+func TestBlobHandlerSendsETagAndRawIsImmutableForPinnedHash(t *testing.T) {
+	repoPath, _, hashMove, _, newPath := setupRepoWithRenamedFileForMainTests(t)
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/repo/testrepo/blob/"+hashMove+"/"+newPath, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("repo", "testrepo")
+		rctx.URLParams.Add("rev", hashMove)
+		rctx.URLParams.Add("*", newPath)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	rr := httptest.NewRecorder()
+	a.blobHandler(rr, newRequest())
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	// The page also shows branches and repos, so it is revalidated.
+	if cc := rr.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Fatalf("expected rendered page to be revalidated, got Cache-Control %q", cc)
+	}
+
+	req := newRequest()
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	a.blobHandler(rr, req)
+	if rr.Code != 304 {
+		t.Fatalf("unexpected status code for matching ETag: got %d want 304", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/repo/testrepo/raw/"+hashMove+"/"+newPath, nil))
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code for raw file: got %d want 200", rr.Code)
+	}
+	if cc := rr.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Fatalf("expected immutable Cache-Control for raw file at pinned hash, got %q", cc)
+	}
+}
+
+func TestRoutesSeparateNestedRepoNamesFromPages(t *testing.T) {
+	repoPath, _, hashMove, _, newPath := setupRepoWithRenamedFileForMainTests(t)
+
+	a := &app{
+		repos:     map[string]string{"team": repoPath, "team/service": repoPath},
+		repoNames: []string{"team", "team/service"},
+	}
+	router := a.routes()
+
+	cases := []struct {
+		path     string
+		want     int
+		contains string
+	}{
+		{path: "/repo/team/service/blob/" + hashMove + "/" + newPath, want: 200, contains: "/repo/team/service/file-history/"},
+		{path: "/repo/team/blob/" + hashMove + "/" + newPath, want: 200, contains: "/repo/team/file-history/"},
+		{path: "/repo/team/service/commit/" + hashMove, want: 200, contains: "moved Android app"},
+		{path: "/repo/team/service", want: 302},
+		{path: "/repo/team/other/tree/HEAD/", want: 404},
+		{path: "/repo/unknown/", want: 404},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+		if rr.Code != tc.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tc.path, rr.Code, tc.want)
+		}
+		if tc.contains != "" && !strings.Contains(rr.Body.String(), tc.contains) {
+			t.Fatalf("%s: expected body to contain %q", tc.path, tc.contains)
+		}
+	}
+}
+
+func TestValidateRepoNameAllowsHierarchicalNames(t *testing.T) {
+	for _, name := range []string{"service", "team-a/service", "org/team/service.git", "status", "tree/service"} {
+		if err := validateRepoName(name); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"/service", "team/", "team//service", "team/../secret", "a?b", "100%", "team/tree", "svc/status", "org/team/commits"} {
+		if err := validateRepoName(name); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+}
+
 func setupRepoWithRenamedFileForMainTests(t *testing.T) (repoPath, hashSwitch, hashMove, oldPath, newPath string) {
 	t.Helper()
 
@@ -131,3 +250,23 @@ fun mainActivityAfter() {
     println(uri)
 }
 `
+
+func TestDebugVarsPublishesOnlyGitMetrics(t *testing.T) {
+	a := &app{repos: map[string]string{}}
+	rr := httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/debug/vars", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, rr.Body.String())
+	}
+	if _, ok := vars["git"]; !ok || len(vars) != 1 {
+		t.Fatalf("expected only the git metrics, got %s", rr.Body.String())
+	}
+	if !strings.Contains(string(vars["git"]), "queue_depth") {
+		t.Fatalf("expected limiter metrics, got %s", vars["git"])
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+type oidcConfig struct {
+	// Issuer is the OpenID provider URL used for discovery.
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"clientId"`
+	// ClientSecret can also be set with GITBROWSER_OIDC_CLIENT_SECRET.
+	ClientSecret string `json:"clientSecret"`
+	// RedirectURL is the externally reachable /auth/callback URL.
+	RedirectURL string   `json:"redirectUrl"`
+	Scopes      []string `json:"scopes"`
+	// UsernameClaim defaults to preferred_username, falling back to email and sub.
+	UsernameClaim string `json:"usernameClaim"`
+	// GroupsClaim names the claim listing the user's groups, "groups" by default.
+	GroupsClaim string `json:"groupsClaim"`
+	// SessionKey signs session cookies. It can also be set with
+	// GITBROWSER_SESSION_KEY; if both are empty a random key is used and
+	// sessions end when the server restarts.
+	SessionKey string `json:"sessionKey"`
+	// SessionTTL is how long a login lasts, "12h" by default.
+	SessionTTL string `json:"sessionTtl"`
+}
+
+const (
+	sessionCookieName    = "gitbrowser_session"
+	loginStateCookieName = "gitbrowser_login"
+	loginStateTTL        = 10 * time.Minute
+	defaultSessionTTL    = 12 * time.Hour
+	discoveryTimeout     = 30 * time.Second
+)
+
+// oidcLogin implements the OpenID Connect authorization code flow. Sessions
+// are stateless: the user and groups are kept in an HMAC-signed cookie.
+type oidcLogin struct {
+	verifier      *oidc.IDTokenVerifier
+	oauth2        oauth2.Config
+	usernameClaim string
+	groupsClaim   string
+	sessionKey    []byte
+	sessionTTL    time.Duration
+	secureCookies bool
+	// publicHost is the host of the redirect URL, which browsers send as the
+	// origin when the server sits behind a proxy that rewrites Host.
+	publicHost    string
+	endSessionURL string
+}
+
+type sessionPayload struct {
+	User    string   `json:"u"`
+	Groups  []string `json:"g,omitempty"`
+	Expires int64    `json:"e"`
+}
+
+type loginStatePayload struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Next     string `json:"r"`
+	Expires  int64  `json:"e"`
+}
+
+func newOIDCLogin(ctx context.Context, cfg oidcConfig) (*oidcLogin, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("auth.oidc requires clientId and redirectUrl")
+	}
+	if secret := os.Getenv("GITBROWSER_OIDC_CLIENT_SECRET"); secret != "" {
+		cfg.ClientSecret = secret
+	}
+
+	discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	provider, err := oidc.NewProvider(discoveryCtx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover OIDC issuer %q: %w", cfg.Issuer, err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email", "groups"}
+	}
+
+	login := &oidcLogin{
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+		},
+		usernameClaim: cfg.UsernameClaim,
+		groupsClaim:   cfg.GroupsClaim,
+		sessionTTL:    defaultSessionTTL,
+		secureCookies: strings.HasPrefix(cfg.RedirectURL, "https://"),
+	}
+	if redirect, err := url.Parse(cfg.RedirectURL); err == nil {
+		login.publicHost = redirect.Host
+	}
+	if login.groupsClaim == "" {
+		login.groupsClaim = "groups"
+	}
+	if cfg.SessionTTL != "" {
+		login.sessionTTL, err = time.ParseDuration(cfg.SessionTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth.oidc.sessionTtl %q: %w", cfg.SessionTTL, err)
+		}
+	}
+
+	sessionKey := cfg.SessionKey
+	if key := os.Getenv("GITBROWSER_SESSION_KEY"); key != "" {
+		sessionKey = key
+	}
+	if sessionKey != "" {
+		login.sessionKey = []byte(sessionKey)
+	} else {
+		login.sessionKey = make([]byte, 32)
+		if _, err := rand.Read(login.sessionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	var discovery struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err == nil {
+		login.endSessionURL = discovery.EndSessionEndpoint
+	}
+
+	return login, nil
+}
+
+// loginHandler starts the authorization code flow with state, nonce and PKCE.
+func (l *oidcLogin) loginHandler(w http.ResponseWriter, r *http.Request) {
+	state := loginStatePayload{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: oauth2.GenerateVerifier(),
+		Next:     safeRedirectTarget(r.URL.Query().Get("next")),
+		Expires:  time.Now().Add(loginStateTTL).Unix(),
+	}
+	l.setCookie(w, loginStateCookieName, l.sign(loginStateCookieName, state), loginStateTTL)
+
+	authURL := l.oauth2.AuthCodeURL(state.State, oidc.Nonce(state.Nonce), oauth2.S256ChallengeOption(state.Verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callbackHandler completes the login and stores the session cookie.
+func (l *oidcLogin) callbackHandler(w http.ResponseWriter, r *http.Request) {
+	var state loginStatePayload
+	cookie, err := r.Cookie(loginStateCookieName)
+	if err != nil || !l.verify(loginStateCookieName, cookie.Value, &state) || state.Expires < time.Now().Unix() {
+		http.Error(w, "Login expired, please try again.", http.StatusBadRequest)
+		return
+	}
+	l.setCookie(w, loginStateCookieName, "", -1)
+
+	if r.URL.Query().Get("state") != state.State {
+		http.Error(w, "Invalid login state.", http.StatusBadRequest)
+		return
+	}
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		http.Error(w, "Login failed: "+errParam, http.StatusForbidden)
+		return
+	}
+
+	token, err := l.oauth2.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(state.Verifier))
+	if err != nil {
+		log.Printf("oidc code exchange: %v", err)
+		http.Error(w, "Login failed.", http.StatusBadGateway)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "Login failed: no ID token.", http.StatusBadGateway)
+		return
+	}
+	idToken, err := l.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil || idToken.Nonce != state.Nonce {
+		log.Printf("oidc verify ID token: %v", err)
+		http.Error(w, "Login failed: invalid ID token.", http.StatusForbidden)
+		return
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(w, "Login failed: unreadable claims.", http.StatusForbidden)
+		return
+	}
+	session := sessionPayload{
+		User:    l.username(claims, idToken.Subject),
+		Groups:  stringList(claims[l.groupsClaim]),
+		Expires: time.Now().Add(l.sessionTTL).Unix(),
+	}
+	l.setCookie(w, sessionCookieName, l.sign(sessionCookieName, session), l.sessionTTL)
+	http.Redirect(w, r, state.Next, http.StatusFound)
+}
+
+// logoutHandler ends the local session and, when the provider supports it,
+// the session at the provider.
+func (l *oidcLogin) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !l.sameOrigin(r) {
+		http.Error(w, "cross-site logout request", http.StatusForbidden)
+		return
+	}
+	l.setCookie(w, sessionCookieName, "", -1)
+	target := "/"
+	if l.endSessionURL != "" {
+		target = l.endSessionURL
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// sameOrigin reports whether r was not sent by another site. Browsers mark
+// cross-site requests with Sec-Fetch-Site and send Origin with every POST;
+// clients that send neither, such as scripts, are not a CSRF risk.
+func (l *oidcLogin) sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Host == r.Host || (l.publicHost != "" && u.Host == l.publicHost)
+}
+
+// sessionUser returns the user name and groups from a valid, unexpired
+// session cookie.
+func (l *oidcLogin) sessionUser(r *http.Request) (string, []string, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return "", nil, false
+	}
+	var session sessionPayload
+	if !l.verify(sessionCookieName, cookie.Value, &session) || session.Expires < time.Now().Unix() || session.User == "" {
+		return "", nil, false
+	}
+	return session.User, session.Groups, true
+}
+
+func (l *oidcLogin) username(claims map[string]any, subject string) string {
+	candidates := []string{"preferred_username", "email"}
+	if l.usernameClaim != "" {
+		candidates = []string{l.usernameClaim}
+	}
+	for _, claim := range candidates {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			return name
+		}
+	}
+	return subject
+}
+
+func (l *oidcLogin) setCookie(w http.ResponseWriter, name, value string, ttl time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   l.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if ttl < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(ttl.Seconds())
+	}
+	http.SetCookie(w, cookie)
+}
+
+// sign encodes payload as JSON followed by its HMAC, both base64url encoded.
+// The purpose, the name of the cookie it is for, is part of the HMAC, so a
+// value signed for one cookie is never accepted as another.
+func (l *oidcLogin) sign(purpose string, payload any) string {
+	data, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(l.mac(purpose, data))
+}
+
+func (l *oidcLogin) verify(purpose, value string, payload any) bool {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	if !hmac.Equal(sum, l.mac(purpose, data)) {
+		return false
+	}
+	return json.Unmarshal(data, payload) == nil
+}
+
+func (l *oidcLogin) mac(purpose string, data []byte) []byte {
+	mac := hmac.New(sha256.New, l.sessionKey)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func randomToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeRedirectTarget only allows local paths, so the login cannot be used to
+// redirect users to other sites.
+func safeRedirectTarget(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func stringList(value any) []string {
+	items, ok := value.([]any)
+	if !ok {
+		if single, ok := value.(string); ok && single != "" {
+			return []string{single}
+		}
+		return nil
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
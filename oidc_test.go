@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-jose/go-jose/v4"
+)
+
+func TestOIDCLoginMapsGroupClaimsToRepoAccess(t *testing.T) {
+	idp := newTestIdentityProvider(t, "alice", []string{"devs"})
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+
+	// The redirect URL must be known before the router exists.
+	var router http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	auth, err := newAuthenticator(context.Background(), authConfig{OIDC: &oidcConfig{
+		Issuer:      idp.URL,
+		ClientID:    "gitbrowser",
+		RedirectURL: server.URL + "/auth/callback",
+		SessionKey:  "test-session-key",
+	}})
+	if err != nil {
+		t.Fatalf("newAuthenticator returned error: %v", err)
+	}
+	a := &app{
+		repos:     map[string]string{"secret": repoPath},
+		repoNames: []string{"secret"},
+		access:    map[string]repoAccess{"secret": newRepoAccess(nil, []string{"devs"})},
+		auth:      auth,
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(a.authMiddleware)
+	r.Get("/auth/login", auth.oidc.loginHandler)
+	r.Get("/auth/callback", auth.oidc.callbackHandler)
+	r.Post("/auth/logout", auth.oidc.logoutHandler)
+	r.Get("/repo/{repo}/whoami", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := a.repoPathFromRequest(w, r); !ok {
+			return
+		}
+		io.WriteString(w, userFromContext(r.Context()).Name)
+	})
+	router = r
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(server.URL + "/repo/secret/whoami")
+	if err != nil {
+		t.Fatalf("login flow failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "alice" {
+		t.Fatalf("expected to land on the page as alice, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Request.URL.Path != "/repo/secret/whoami" {
+		t.Fatalf("expected redirect back to the requested page, got %s", resp.Request.URL)
+	}
+
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err = client.Post(server.URL+"/auth/logout", "", nil)
+	if err != nil {
+		t.Fatalf("logout failed: %v", err)
+	}
+	resp.Body.Close()
+	if location := resp.Header.Get("Location"); location != idp.URL+"/logout" {
+		t.Fatalf("expected redirect to the provider logout, got %q", location)
+	}
+
+	resp, err = client.Get(server.URL + "/repo/secret/whoami")
+	if err != nil {
+		t.Fatalf("request after logout failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(resp.Header.Get("Location"), "/auth/login?next=") {
+		t.Fatalf("expected redirect to login after logout, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestOIDCSessionWithoutGroupIsDenied(t *testing.T) {
+	login := &oidcLogin{sessionKey: []byte("key"), sessionTTL: time.Hour}
+	a := &app{
+		repos:     map[string]string{"secret": "/nonexistent"},
+		repoNames: []string{"secret"},
+		access:    map[string]repoAccess{"secret": newRepoAccess(nil, []string{"devs"})},
+		auth:      &authenticator{groups: map[string][]string{}, oidc: login},
+	}
+	handler := a.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("repo", "secret")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		if _, _, ok := a.repoPathFromRequest(w, r); ok {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	cases := []struct {
+		name   string
+		cookie string
+		want   int
+	}{
+		{name: "other group", cookie: login.sign(sessionCookieName, sessionPayload{User: "bob", Groups: []string{"ops"}, Expires: time.Now().Add(time.Hour).Unix()}), want: http.StatusNotFound},
+		{name: "expired", cookie: login.sign(sessionCookieName, sessionPayload{User: "alice", Groups: []string{"devs"}, Expires: time.Now().Add(-time.Hour).Unix()}), want: http.StatusFound},
+		{name: "forged", cookie: (&oidcLogin{sessionKey: []byte("other")}).sign(sessionCookieName, sessionPayload{User: "alice", Groups: []string{"devs"}, Expires: time.Now().Add(time.Hour).Unix()}), want: http.StatusFound},
+		{name: "signed for the login cookie", cookie: login.sign(loginStateCookieName, sessionPayload{User: "alice", Groups: []string{"devs"}, Expires: time.Now().Add(time.Hour).Unix()}), want: http.StatusFound},
+		{name: "member", cookie: login.sign(sessionCookieName, sessionPayload{User: "alice", Groups: []string{"devs"}, Expires: time.Now().Add(time.Hour).Unix()}), want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/repo/secret/", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tc.cookie})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tc.name, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestOIDCLogoutRejectsCrossSiteRequests(t *testing.T) {
+	login := &oidcLogin{sessionKey: []byte("key"), publicHost: "git.example.com"}
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "no browser headers", want: http.StatusFound},
+		{name: "same origin", headers: map[string]string{"Origin": "http://gitbrowser.local", "Sec-Fetch-Site": "same-origin"}, want: http.StatusFound},
+		{name: "public host", headers: map[string]string{"Origin": "https://git.example.com"}, want: http.StatusFound},
+		{name: "other origin", headers: map[string]string{"Origin": "https://evil.example"}, want: http.StatusForbidden},
+		{name: "opaque origin", headers: map[string]string{"Origin": "null"}, want: http.StatusForbidden},
+		{name: "cross site", headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+		{name: "same site", headers: map[string]string{"Sec-Fetch-Site": "same-site"}, want: http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "http://gitbrowser.local/auth/logout", nil)
+		for name, value := range tc.headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		login.logoutHandler(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s: unexpected status code: got %d want %d", tc.name, rr.Code, tc.want)
+		}
+		if cleared := strings.Contains(rr.Header().Get("Set-Cookie"), sessionCookieName); cleared != (tc.want == http.StatusFound) {
+			t.Fatalf("%s: session cookie cleared = %v", tc.name, cleared)
+		}
+	}
+}
+
+func TestSafeRedirectTargetRejectsOtherSites(t *testing.T) {
+	cases := map[string]string{
+		"/repo/a/":            "/repo/a/",
+		"":                    "/",
+		"https://example.com": "/",
+		"//example.com/x":     "/",
+		"/\\example.com":      "/",
+	}
+	for next, want := range cases {
+		if got := safeRedirectTarget(next); got != want {
+			t.Fatalf("safeRedirectTarget(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
+
+// newTestIdentityProvider starts a minimal OpenID provider that logs in the
+// given user without prompting.
+func newTestIdentityProvider(t *testing.T, name string, groups []string) *httptest.Server {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatalf("create signer: %v", err)
+	}
+
+	type pending struct {
+		nonce     string
+		challenge string
+	}
+	codes := make(map[string]pending)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                server.URL,
+			"authorization_endpoint":                server.URL + "/authorize",
+			"token_endpoint":                        server.URL + "/token",
+			"jwks_uri":                              server.URL + "/keys",
+			"end_session_endpoint":                  server.URL + "/logout",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "PKCE required", http.StatusBadRequest)
+			return
+		}
+		code := randomToken()
+		codes[code] = pending{nonce: query.Get("nonce"), challenge: query.Get("code_challenge")}
+		target, _ := url.Parse(query.Get("redirect_uri"))
+		target.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p, ok := codes[r.PostForm.Get("code")]
+		delete(codes, r.PostForm.Get("code"))
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		claims, _ := json.Marshal(map[string]any{
+			"iss":                server.URL,
+			"aud":                "gitbrowser",
+			"sub":                "user-1",
+			"iat":                time.Now().Unix(),
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"nonce":              p.nonce,
+			"preferred_username": name,
+			"groups":             groups,
+		})
+		signed, err := signer.Sign(claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		idToken, _ := signed.CompactSerialize()
+		writeJSON(w, map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+	})
+	return server
+}
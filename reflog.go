@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/andrebering/gitBrowser/git"
+	"github.com/go-chi/chi/v5"
+)
+
+type reflogViewData struct {
+	baseViewData
+	Reflog git.ReflogResult
+}
+
+// reflogHandler lists the reflog of a ref, HEAD by default, to help find
+// commits lost by a reset or rebase.
+func (a *app) reflogHandler(w http.ResponseWriter, r *http.Request) {
+	repoName, repoPath, ok := a.repoPathFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	ref := strings.Trim(chi.URLParam(r, "*"), "/")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	reflog, err := git.GetReflog(ctx, repoPath, ref)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	rev, _ := git.GetDefaultBranch(ctx, repoPath)
+	data := reflogViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
+		Reflog:       reflog,
+	}
+	render(w, r, "reflog.html", data)
+}
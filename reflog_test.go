@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReflogPageLinksLostCommits(t *testing.T) {
+	repoPath, _, hashMove, _, _ := setupRepoWithRenamedFileForMainTests(t)
+	runGitMainTest(t, repoPath, "reset", "-q", "--hard", "HEAD~2")
+	branch := runGitMainTest(t, repoPath, "branch", "--show-current")
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+	router := a.routes()
+
+	for _, path := range []string{"/repo/testrepo/reflog", "/repo/testrepo/reflog/refs/heads/" + branch, "/repo/testrepo/reflog/" + branch} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: unexpected status code: got %d want 200", path, rr.Code)
+		}
+		body := rr.Body.String()
+		for _, want := range []string{`href="/repo/testrepo/commit/` + hashMove + `"`, `href="/repo/testrepo/tree/`, "moving to HEAD~2"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("%s: expected reflog page to contain %q, got body %q", path, want, body)
+			}
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/repo/testrepo/reflog/no-such-branch", nil))
+	if rr.Code != 404 {
+		t.Fatalf("unexpected status code for unknown ref: got %d want 404", rr.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 2 * time.Second
+
+// reload re-reads the config file and replaces the repository set and git
+// settings. If the new config is invalid, the current one stays in place.
+// The auth section is only read at startup.
+func (a *app) reload(path string) error {
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	next, err := newApp(config, a.auth)
+	if err != nil {
+		return err
+	}
+	if err := applyGitConfig(config.Git); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	previous := a.repos
+	a.repos = next.repos
+	a.repoNames = next.repoNames
+	a.details = next.details
+	a.access = next.access
+	a.scanInterval = next.scanInterval
+	a.mu.Unlock()
+
+	var added, removed, changed []string
+	for _, name := range next.repoNames {
+		oldPath, existed := previous[name]
+		switch {
+		case !existed:
+			added = append(added, name)
+		case oldPath != next.repos[name]:
+			changed = append(changed, name)
+		}
+	}
+	inUse := make(map[string]bool, len(next.repos))
+	for _, repoPath := range next.repos {
+		inUse[repoPath] = true
+		git.ReopenObjectReader(repoPath)
+	}
+	a.summaries.retain(inUse)
+	for name, oldPath := range previous {
+		if _, kept := next.repos[name]; !kept {
+			removed = append(removed, name)
+		}
+		if !inUse[oldPath] {
+			git.CloseObjectReader(oldPath)
+			git.ForgetCommonDir(oldPath)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		return nil
+	}
+	slices.Sort(removed)
+	log.Printf("Config %s reloaded: %d repos, added %v, removed %v, path changed %v", path, len(next.repoNames), added, removed, changed)
+	return nil
+}
+
+// watchConfig reloads the config file on SIGHUP, whenever its size or
+// modification time changes, and every scan interval to pick up repositories
+// added below scan roots, until ctx is done.
+func (a *app) watchConfig(ctx context.Context, path string, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	lastReload := time.Now()
+	for {
+		reload := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload = true
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				// Editors may replace the file; wait until it is back.
+				continue
+			}
+			if last == nil || info.Size() != last.Size() || !info.ModTime().Equal(last.ModTime()) {
+				reload = true
+			}
+			a.mu.RLock()
+			scanInterval := a.scanInterval
+			a.mu.RUnlock()
+			if scanInterval > 0 && time.Since(lastReload) >= scanInterval {
+				reload = true
+			}
+		}
+		if !reload {
+			continue
+		}
+		last, _ = os.Stat(path)
+		lastReload = time.Now()
+		if err := a.reload(path); err != nil {
+			log.Printf("Config %s not reloaded, keeping the current repos: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloadSwapsReposAndKeepsThemOnInvalidConfig(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+	cfgPath := filepath.Join(t.TempDir(), "repos.json")
+	writeFileMainTest(t, cfgPath, `{"repos": [{"name": "one", "path": "`+repoPath+`"}]}`)
+	t.Setenv("GITBROWSER_CONFIG", cfgPath)
+
+	a, err := loadApp()
+	if err != nil {
+		t.Fatalf("loadApp returned error: %v", err)
+	}
+
+	a.summaries.entries = map[string]cachedSummary{repoPath: {}, "/removed/repo": {}}
+
+	writeFileMainTest(t, cfgPath, `{"repos": [{"name": "two", "path": "`+repoPath+`"}, {"name": "three", "path": "`+repoPath+`"}]}`)
+	if err := a.reload(cfgPath); err != nil {
+		t.Fatalf("reload returned error: %v", err)
+	}
+	if got := a.visibleRepos(context.Background()); !reflect.DeepEqual(got, []string{"two", "three"}) {
+		t.Fatalf("unexpected repos after reload: %v", got)
+	}
+	if _, ok := a.summaries.entries["/removed/repo"]; ok || len(a.summaries.entries) != 1 {
+		t.Fatalf("expected only summaries of configured repos to be kept, got %v", a.summaries.entries)
+	}
+
+	writeFileMainTest(t, cfgPath, `{"repos": [{"name": "four", "path": "/does/not/exist"}]}`)
+	if err := a.reload(cfgPath); err == nil {
+		t.Fatalf("expected reload of invalid config to fail")
+	}
+	if got := a.visibleRepos(context.Background()); !reflect.DeepEqual(got, []string{"two", "three"}) {
+		t.Fatalf("expected previous repos to be kept, got %v", got)
+	}
+}
+
+func TestWatchConfigReloadsChangedFile(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+	cfgPath := filepath.Join(t.TempDir(), "repos.json")
+	writeFileMainTest(t, cfgPath, `{"repos": [{"name": "one", "path": "`+repoPath+`"}]}`)
+	t.Setenv("GITBROWSER_CONFIG", cfgPath)
+
+	a, err := loadApp()
+	if err != nil {
+		t.Fatalf("loadApp returned error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.watchConfig(ctx, cfgPath, 10*time.Millisecond)
+
+	writeFileMainTest(t, cfgPath, `{"repos": [{"name": "renamed", "path": "`+repoPath+`"}]}`)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 1; !a.canRead(context.Background(), "renamed"); i++ {
+		if time.Now().After(deadline) {
+			t.Fatalf("config change was not picked up, repos: %v", a.visibleRepos(context.Background()))
+		}
+		// Keep touching the file, as the watcher may have taken its first
+		// look after the write.
+		later := time.Now().Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(cfgPath, later, later); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
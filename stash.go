@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/andrebering/gitBrowser/git"
+	"github.com/go-chi/chi/v5"
+)
+
+type stashListViewData struct {
+	baseViewData
+	Stashes []git.StashEntry
+}
+
+// stashListHandler lists the stashes of a repository.
+func (a *app) stashListHandler(w http.ResponseWriter, r *http.Request) {
+	repoName, repoPath, ok := a.repoPathFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	stashes, err := git.GetStashes(ctx, repoPath)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	rev, _ := git.GetDefaultBranch(ctx, repoPath)
+	data := stashListViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
+		Stashes:      stashes,
+	}
+	render(w, r, "stash.html", data)
+}
+
+// stashHandler shows a stash like a commit. Stashes are addressed by hash,
+// since their stash@{N} names shift when stashes are added or dropped.
+func (a *app) stashHandler(w http.ResponseWriter, r *http.Request) {
+	repoName, repoPath, ok := a.repoPathFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	hash := chi.URLParam(r, "hash")
+
+	stashes, err := git.GetStashes(ctx, repoPath)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+	var stash *git.StashEntry
+	for i := range stashes {
+		if stashes[i].Hash == hash {
+			stash = &stashes[i]
+			break
+		}
+	}
+	if stash == nil {
+		a.notFound(w, r)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "raw" {
+		markImmutable(w, r, hash)
+		a.stream(w, r, "text/plain; charset=utf-8", func(w io.Writer) error {
+			return git.WriteStashDiff(ctx, repoPath, w, hash)
+		})
+		return
+	}
+
+	diff, err := git.GetStashDiff(ctx, repoPath, hash)
+	tooLarge := errors.Is(err, git.ErrTooLarge)
+	if err != nil && !tooLarge {
+		a.renderError(w, r, err)
+		return
+	}
+
+	rev, _ := git.GetDefaultBranch(ctx, repoPath)
+	data := commitViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
+		Title:        stash.Name + ": " + stash.Message,
+		Hash:         hash,
+		Diff:         diff,
+		TooLarge:     tooLarge,
+		RawURL:       "/repo/" + repoName + "/stash/" + hash + "?format=raw",
+	}
+	render(w, r, "commit.html", data)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStashPagesListAndShowStashes(t *testing.T) {
+	repoPath, _, hashMove, _, newPath := setupRepoWithRenamedFileForMainTests(t)
+	writeFileMainTest(t, filepath.Join(repoPath, newPath), "stashed work\n")
+	runGitMainTest(t, repoPath, "stash", "push", "-q", "-m", "half done")
+	stash := runGitMainTest(t, repoPath, "rev-parse", "stash@{0}")
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+	router := a.routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	body := get("/repo/testrepo/stash").Body.String()
+	if !strings.Contains(body, `href="/repo/testrepo/stash/`+stash+`"`) || !strings.Contains(body, "half done") {
+		t.Fatalf("expected stash list to link the stash, got body %q", body)
+	}
+
+	rr := get("/repo/testrepo/stash/" + stash)
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "stash@{0}: On ") || !strings.Contains(body, "&#43;stashed work") {
+		t.Fatalf("expected stash diff, got body %q", body)
+	}
+	// The stash@{N} name changes as stashes come and go.
+	if cc := rr.Header().Get("Cache-Control"); strings.Contains(cc, "immutable") {
+		t.Fatalf("expected stash page to be revalidated, got Cache-Control %q", cc)
+	}
+	if rr := get("/repo/testrepo/stash/" + stash + "?format=raw"); !strings.Contains(rr.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("expected raw stash diff to be immutable, got Cache-Control %q", rr.Header().Get("Cache-Control"))
+	}
+
+	// Commits that are not stashes are not shown here.
+	if rr := get("/repo/testrepo/stash/" + hashMove); rr.Code != 404 {
+		t.Fatalf("unexpected status code for non-stash commit: got %d want 404", rr.Code)
+	}
+}
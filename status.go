@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/andrebering/gitBrowser/git"
+	"github.com/go-chi/chi/v5"
+)
+
+type statusViewData struct {
+	baseViewData
+	Status   git.Status
+	Sections []statusSection
+}
+
+type statusSection struct {
+	Title   string
+	Entries []git.StatusEntry
+}
+
+type statusFileViewData struct {
+	baseViewData
+	Entry            git.StatusEntry
+	StagedDiff       string
+	UnstagedDiff     string
+	StagedTooLarge   bool
+	UnstagedTooLarge bool
+}
+
+// statusHandler lists the uncommitted changes of a repository's work tree.
+func (a *app) statusHandler(w http.ResponseWriter, r *http.Request) {
+	repoName, repoPath, ok := a.repoPathFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	status, err := git.GetStatus(ctx, repoPath)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	rev := status.Branch
+	if rev == "" {
+		rev = "HEAD"
+	}
+	var conflicted, staged, unstaged, untracked []git.StatusEntry
+	for _, entry := range status.Entries {
+		switch {
+		case entry.Conflicted:
+			conflicted = append(conflicted, entry)
+		case entry.Untracked:
+			untracked = append(untracked, entry)
+		default:
+			if entry.Staged() {
+				staged = append(staged, entry)
+			}
+			if entry.Unstaged() {
+				unstaged = append(unstaged, entry)
+			}
+		}
+	}
+	data := statusViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
+		Status:       status,
+	}
+	for _, section := range []statusSection{
+		{Title: "Conflicts", Entries: conflicted},
+		{Title: "Staged", Entries: staged},
+		{Title: "Not staged", Entries: unstaged},
+		{Title: "Untracked", Entries: untracked},
+	} {
+		if len(section.Entries) > 0 {
+			data.Sections = append(data.Sections, section)
+		}
+	}
+	render(w, r, "status.html", data)
+}
+
+// statusFileHandler shows the staged and unstaged diff of one changed file.
+// Only paths reported by git status are accepted, so arbitrary files cannot
+// be read through the untracked file diff.
+func (a *app) statusFileHandler(w http.ResponseWriter, r *http.Request) {
+	repoName, repoPath, ok := a.repoPathFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	path := strings.TrimPrefix(chi.URLParam(r, "*"), "/")
+
+	status, err := git.GetStatus(ctx, repoPath)
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+	var entry *git.StatusEntry
+	for i := range status.Entries {
+		if status.Entries[i].Path == path {
+			entry = &status.Entries[i]
+			break
+		}
+	}
+	if entry == nil || entry.IsDir() {
+		a.notFound(w, r)
+		return
+	}
+
+	rev := status.Branch
+	if rev == "" {
+		rev = "HEAD"
+	}
+	data := statusFileViewData{
+		baseViewData: a.baseData(ctx, repoName, repoPath, rev),
+		Entry:        *entry,
+	}
+
+	paths := []string{entry.Path}
+	if entry.OrigPath != "" {
+		paths = append(paths, entry.OrigPath)
+	}
+	switch {
+	case entry.Untracked:
+		data.UnstagedDiff, err = git.GetUntrackedDiff(ctx, repoPath, entry.Path)
+		data.UnstagedTooLarge = errors.Is(err, git.ErrTooLarge)
+	case entry.Conflicted:
+		data.UnstagedDiff, err = git.GetWorkTreeDiff(ctx, repoPath, false, paths...)
+		data.UnstagedTooLarge = errors.Is(err, git.ErrTooLarge)
+	default:
+		if entry.Staged() {
+			data.StagedDiff, err = git.GetWorkTreeDiff(ctx, repoPath, true, paths...)
+			data.StagedTooLarge = errors.Is(err, git.ErrTooLarge)
+		}
+		if err == nil || data.StagedTooLarge {
+			if entry.Unstaged() {
+				data.UnstagedDiff, err = git.GetWorkTreeDiff(ctx, repoPath, false, entry.Path)
+				data.UnstagedTooLarge = errors.Is(err, git.ErrTooLarge)
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, git.ErrTooLarge) {
+		a.renderError(w, r, err)
+		return
+	}
+	render(w, r, "status_file.html", data)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusPagesShowWorkTreeChanges(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFileForMainTests(t)
+	writeFileMainTest(t, filepath.Join(repoPath, newPath), "staged change\n")
+	runGitMainTest(t, repoPath, "add", newPath)
+	writeFileMainTest(t, filepath.Join(repoPath, newPath), "staged change\nunstaged change\n")
+	writeFileMainTest(t, filepath.Join(repoPath, "notes.txt"), "untracked content\n")
+	writeFileMainTest(t, filepath.Join(repoPath, "build", "out.bin"), "output\n")
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+	router := a.routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	rr := get("/repo/testrepo/status")
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"Staged (1)", "Not staged (1)", "Untracked (2)", `href="/repo/testrepo/status/notes.txt"`, "<span>build/</span>"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected status page to contain %q, got body %q", want, body)
+		}
+	}
+
+	body = get("/repo/testrepo/status/" + newPath).Body.String()
+	if !strings.Contains(body, "&#43;staged change") || !strings.Contains(body, "&#43;unstaged change") {
+		t.Fatalf("expected staged and unstaged diffs, got body %q", body)
+	}
+	body = get("/repo/testrepo/status/notes.txt").Body.String()
+	if !strings.Contains(body, "untracked content") {
+		t.Fatalf("expected untracked file content, got body %q", body)
+	}
+
+	// Only files git reports as changed can be viewed.
+	for _, path := range []string{"/repo/testrepo/status/unchanged.txt", "/repo/testrepo/status/../../../etc/passwd", "/repo/testrepo/status/build/", "/repo/testrepo/status/build/out.bin"} {
+		if rr := get(path); rr.Code != 404 {
+			t.Fatalf("%s: unexpected status code: got %d want 404", path, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+// submoduleView is a submodule entry of a tree. Repo names the configured
+// repo checked out at the submodule's path, if the user may read one.
+type submoduleView struct {
+	git.Submodule
+	// Commit is the commit the submodule is pinned to at this revision.
+	Commit string
+	Repo   string
+}
+
+// submodules describes the submodule entries among entries, keyed by name.
+// It returns nil when there are none.
+func (a *app) submodules(ctx context.Context, repoPath, rev string, entries []git.TreeEntry) map[string]submoduleView {
+	var views map[string]submoduleView
+	var declared map[string]git.Submodule
+	for _, entry := range entries {
+		if entry.Type != "commit" {
+			continue
+		}
+		if views == nil {
+			views = make(map[string]submoduleView)
+			var err error
+			declared, err = git.GetSubmodules(ctx, repoPath, rev)
+			if err != nil {
+				log.Printf("submodules at %s: %v", rev, err)
+			}
+		}
+		sub, ok := declared[entry.Path]
+		if !ok {
+			sub = git.Submodule{Path: entry.Path}
+		}
+		view := submoduleView{Submodule: sub, Commit: entry.Hash}
+		view.Repo, _ = a.readableRepoAt(ctx, filepath.Join(repoPath, filepath.FromSlash(entry.Path)))
+		views[entry.Name] = view
+	}
+	return views
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTreeShowsSubmodulesAtPinnedCommit(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+	depPath, depCommit, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+
+	writeFileMainTest(t, filepath.Join(repoPath, ".gitmodules"), `[submodule "dep"]
+	path = libs/dep
+	url = https://example.com/dep.git
+[submodule "other"]
+	path = libs/other
+	url = git@example.com:other.git
+`)
+	runGitMainTest(t, repoPath, "add", ".gitmodules")
+	runGitMainTest(t, repoPath, "update-index", "--add", "--cacheinfo", "160000,"+depCommit+",libs/dep")
+	runGitMainTest(t, repoPath, "update-index", "--add", "--cacheinfo", "160000,"+depCommit+",libs/other")
+	runGitMainTest(t, repoPath, "commit", "-q", "-m", "add submodules")
+
+	// libs/dep is checked out as a repository of its own and configured.
+	checkout := filepath.Join(repoPath, "libs", "dep")
+	runGitMainTest(t, repoPath, "clone", "-q", depPath, checkout)
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath, "dep": checkout},
+		repoNames: []string{"testrepo", "dep"},
+	}
+	rr := httptest.NewRecorder()
+	a.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/repo/testrepo/tree/HEAD/libs", nil))
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		`href="/repo/dep/tree/` + depCommit + `/">dep</a>`,
+		`<a href="https://example.com/dep.git">`,
+		"git@example.com:other.git",
+		depCommit[:8],
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected tree to contain %q, got body %q", want, body)
+		}
+	}
+	if strings.Contains(body, "/blob/HEAD/libs/") {
+		t.Fatalf("expected submodules not to link to the blob view, got body %q", body)
+	}
+}
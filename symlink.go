@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+// symlinkView is a resolved symlink with what the "symlink-target" template
+// needs to link to its target.
+type symlinkView struct {
+	*git.Symlink
+	Repo string
+	Rev  string
+}
+
+// symlinks resolves the symlink entries among entries, listed at commit, keyed
+// by name. Links that cannot be resolved are left out and shown like files.
+func symlinks(ctx context.Context, repoName, repoPath, rev, commit string, entries []git.TreeEntry) map[string]symlinkView {
+	var views map[string]symlinkView
+	for _, entry := range entries {
+		if !entry.IsSymlink() {
+			continue
+		}
+		link, err := git.ResolveSymlink(ctx, repoPath, commit, entry)
+		if err != nil {
+			log.Printf("symlink %s:%s: %v", rev, entry.Path, err)
+			continue
+		}
+		if views == nil {
+			views = make(map[string]symlinkView)
+		}
+		views[entry.Name] = symlinkView{Symlink: link, Repo: repoName, Rev: rev}
+	}
+	return views
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTreeAndBlobShowSymlinkTargets(t *testing.T) {
+	repoPath, _, _, _, newPath := setupRepoWithRenamedFileForMainTests(t)
+	for name, target := range map[string]string{
+		"main.kt": newPath,
+		"android": "Android",
+		"secrets": "/etc/passwd",
+		"gone":    "missing.txt",
+	} {
+		if err := os.Symlink(target, filepath.Join(repoPath, name)); err != nil {
+			t.Fatalf("symlink %s: %v", name, err)
+		}
+	}
+	runGitMainTest(t, repoPath, "add", ".")
+	runGitMainTest(t, repoPath, "commit", "-q", "-m", "add links")
+
+	a := &app{
+		repos:     map[string]string{"testrepo": repoPath},
+		repoNames: []string{"testrepo"},
+	}
+	router := a.routes()
+
+	get := func(path string) string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: unexpected status code: got %d want 200", path, rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	body := get("/repo/testrepo/tree/HEAD/")
+	for _, want := range []string{
+		"octicon-file-symlink-file",
+		`href="/repo/testrepo/blob/HEAD/` + newPath + `">` + newPath + `</a>`,
+		`href="/repo/testrepo/tree/HEAD/Android">Android</a>`,
+		"outside repository",
+		"broken",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected tree to contain %q, got body %q", want, body)
+		}
+	}
+
+	body = get("/repo/testrepo/blob/HEAD/main.kt")
+	if !strings.Contains(body, "Symbolic link") || !strings.Contains(body, `href="/repo/testrepo/blob/HEAD/`+newPath+`"`) || strings.Contains(body, "blob-line") {
+		t.Fatalf("expected blob view to show the link target, got body %q", body)
+	}
+	if body := get("/repo/testrepo/blob/HEAD/secrets"); !strings.Contains(body, "outside repository") || strings.Contains(body, "root:") {
+		t.Fatalf("expected escaping link to be flagged, got body %q", body)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/andrebering/gitBrowser/git"
+)
+
+// worktreeView is a worktree shown on the repo page. Repo names the
+// configured repo serving the worktree's path, if the user may read one.
+type worktreeView struct {
+	git.Worktree
+	Repo string
+}
+
+// worktrees returns the working trees of the repository at repoPath, or nil
+// when it has no linked worktrees.
+func (a *app) worktrees(ctx context.Context, repoPath string) []worktreeView {
+	worktrees, err := git.GetWorktrees(ctx, repoPath)
+	if err != nil {
+		log.Printf("worktrees of %s: %v", repoPath, err)
+		return nil
+	}
+	if len(worktrees) < 2 {
+		return nil
+	}
+
+	views := make([]worktreeView, len(worktrees))
+	for i, wt := range worktrees {
+		views[i].Worktree = wt
+		views[i].Repo, _ = a.readableRepoAt(ctx, wt.Path)
+	}
+	return views
+}
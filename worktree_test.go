@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktreesAreListedAndBrowsable(t *testing.T) {
+	repoPath, _, _, _, _ := setupRepoWithRenamedFileForMainTests(t)
+	base := t.TempDir()
+	feature := filepath.Join(base, "feature")
+	gone := filepath.Join(base, "gone")
+	runGitMainTest(t, repoPath, "worktree", "add", "-q", "-b", "feature", feature)
+	runGitMainTest(t, repoPath, "worktree", "lock", feature)
+	runGitMainTest(t, repoPath, "worktree", "add", "-q", "--detach", gone)
+	// Served as testrepo/tree, it would hide the file pages of testrepo.
+	runGitMainTest(t, repoPath, "worktree", "add", "-q", "--detach", filepath.Join(base, "tree"))
+	if err := os.RemoveAll(gone); err != nil {
+		t.Fatalf("remove worktree: %v", err)
+	}
+	writeFileMainTest(t, filepath.Join(feature, "notes.txt"), "work in progress\n")
+
+	a, err := newApp(appConfig{Repos: []repoConfig{{Name: "testrepo", Path: repoPath, Worktrees: true}}}, nil)
+	if err != nil {
+		t.Fatalf("newApp returned error: %v", err)
+	}
+	if got := a.repos["testrepo/feature"]; got != feature {
+		t.Fatalf("expected worktree to be served as testrepo/feature, got repos %v", a.repos)
+	}
+	if _, ok := a.repos["testrepo/gone"]; ok {
+		t.Fatalf("expected prunable worktree to be skipped, got repos %v", a.repos)
+	}
+	if _, ok := a.repos["testrepo/tree"]; ok {
+		t.Fatalf("expected worktree named like a page to be skipped, got repos %v", a.repos)
+	}
+	if a.scanInterval != defaultScanInterval {
+		t.Fatalf("expected worktrees to be refreshed every %v, got %v", defaultScanInterval, a.scanInterval)
+	}
+	router := a.routes()
+
+	head := runGitMainTest(t, repoPath, "rev-parse", "HEAD")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/repo/testrepo/tree/"+head+"/", nil))
+	if rr.Code != 200 {
+		t.Fatalf("unexpected status code: got %d want 200", rr.Code)
+	}
+	// The worktree list is live state even at a pinned commit.
+	if cc := rr.Header().Get("Cache-Control"); strings.Contains(cc, "immutable") {
+		t.Fatalf("expected repo page with worktrees to be revalidated, got Cache-Control %q", cc)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"Worktrees", `href="/repo/testrepo/feature/">` + feature, `href="/repo/testrepo/feature/status"`, ">locked<", gone, ">prunable<"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected repo page to contain %q, got body %q", want, body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/repo/testrepo/feature/status", nil))
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), "notes.txt") || !strings.Contains(rr.Body.String(), "On branch feature") {
+		t.Fatalf("expected worktree changes page, got %d %q", rr.Code, rr.Body.String())
+	}
+}